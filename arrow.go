@@ -0,0 +1,212 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// arrow is a circle with a line coming out of it. The digits along the line
+// add up to the digit in the circle. They may repeat unless other rules
+// forbid it.
+type arrow struct {
+	circle int
+	// cells are the cells on the line, starting next to the circle.
+	cells []int
+}
+
+// arrowConstraint holds the arrows of an arrow sudoku.
+type arrowConstraint struct {
+	arrows []arrow
+}
+
+func (c arrowConstraint) houses() [][]int {
+	return nil
+}
+
+func (c arrowConstraint) allows(g grid, i, n int) bool {
+	for _, a := range c.arrows {
+		if a.circle != i && indexOf(a.cells, i) == -1 {
+			continue
+		}
+
+		circle := g[a.circle]
+		if a.circle == i {
+			circle = n
+		}
+		sum, empty := 0, 0
+		for _, j := range a.cells {
+			switch {
+			case j == i:
+				sum += n
+			case g[j] == 0:
+				empty++
+			default:
+				sum += g[j]
+			}
+		}
+
+		// Every empty cell on the line holds at least 1 and at most 9.
+		min, max := sum+empty, sum+9*empty
+		if circle == 0 && min > 9 || circle != 0 && (circle < min || circle > max) {
+			return false
+		}
+	}
+	return true
+}
+
+// conflicts returns arrows whose line already adds up to more than the
+// circle, or whose full line adds up to a different digit.
+func (c arrowConstraint) conflicts(g grid) [][]int {
+	var conflicts [][]int
+	for _, a := range c.arrows {
+		if g[a.circle] == 0 {
+			continue
+		}
+		filled := []int{a.circle}
+		sum := 0
+		for _, i := range a.cells {
+			if g[i] != 0 {
+				filled = append(filled, i)
+				sum += g[i]
+			}
+		}
+		full := len(filled) == len(a.cells)+1
+		if sum > g[a.circle] || full && sum != g[a.circle] {
+			conflicts = append(conflicts, filled)
+		}
+	}
+	return conflicts
+}
+
+func (c arrowConstraint) hints() []hint {
+	var hints []hint
+	for _, a := range c.arrows {
+		hints = append(hints, hint{
+			kind:  arrowHint,
+			cells: append([]int{a.circle}, a.cells...),
+		})
+	}
+	return hints
+}
+
+// symmetricUnder returns true if t moves every arrow onto another one. The
+// digits must stay the same, relabeling them changes the sums.
+func (c arrowConstraint) symmetricUnder(t transform) bool {
+	if t.digits != identityTransform().digits {
+		return false
+	}
+	key := func(circle int, cells []int) string {
+		return strings.Join(cellNames(append([]int{circle}, cells...)), " ")
+	}
+	move := func(i int) int {
+		col, row := t.target(i%9, i/9)
+		return col + 9*row
+	}
+	have := make(map[string]bool)
+	for _, a := range c.arrows {
+		have[key(a.circle, a.cells)] = true
+	}
+	for _, a := range c.arrows {
+		moved := make([]int, len(a.cells))
+		for j, i := range a.cells {
+			moved[j] = move(i)
+		}
+		if !have[key(move(a.circle), moved)] {
+			return false
+		}
+	}
+	return true
+}
+
+// withArrow returns the rules with the arrow added to their arrow constraint,
+// which is created if the rules have none yet.
+func (r rules) withArrow(a arrow) rules {
+	result := append(rules(nil), r...)
+	for k, c := range result {
+		if arrows, ok := c.(arrowConstraint); ok {
+			result[k] = arrowConstraint{
+				arrows: append(append([]arrow(nil), arrows.arrows...), a),
+			}
+			return result
+		}
+	}
+	return append(result, arrowConstraint{arrows: []arrow{a}})
+}
+
+// withoutArrowsAt returns the rules without the arrows that go through cell
+// i, their circle included.
+func (r rules) withoutArrowsAt(i int) rules {
+	result := append(rules(nil), r...)
+	for k, c := range result {
+		if arrows, ok := c.(arrowConstraint); ok {
+			var keep []arrow
+			for _, a := range arrows.arrows {
+				if a.circle != i && indexOf(a.cells, i) == -1 {
+					keep = append(keep, a)
+				}
+			}
+			result[k] = arrowConstraint{arrows: keep}
+		}
+	}
+	return result
+}
+
+// newArrowGame generates an arrow sudoku. The arrows are laid along digits of
+// a random solution that add up to the digit in their circle. Then givens are
+// removed as long as the game stays unique.
+func newArrowGame(extra rules, givenDigits int) (rules, grid, error) {
+	r := append(classicRules(), extra...)
+	solution, err := r.randomSolution()
+	if err != nil {
+		return r, solution, err
+	}
+	r = append(r, arrowConstraint{arrows: randomArrows(solution)})
+	return r, removeGivens(r, solution, givenDigits), nil
+}
+
+// randomArrows finds up to 8 arrows with lines of 2 to 4 cells in the
+// solution. Arrows do not share cells.
+func randomArrows(solution grid) []arrow {
+	var used [81]bool
+	var arrows []arrow
+
+	// findLine extends the line by cells whose digits add up to sum.
+	var findLine func(line []int, sum int) []int
+	findLine = func(line []int, sum int) []int {
+		if sum == 0 {
+			if len(line) >= 3 {
+				return line
+			}
+			return nil
+		}
+		if len(line) == 5 {
+			return nil
+		}
+		next := kingNeighbors(line[len(line)-1])
+		for _, k := range rand.Perm(len(next)) {
+			j := next[k]
+			if !used[j] && indexOf(line, j) == -1 && solution[j] <= sum {
+				if found := findLine(append(line, j), sum-solution[j]); found != nil {
+					return found
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, circle := range rand.Perm(81) {
+		if len(arrows) == 8 {
+			break
+		}
+		if used[circle] || solution[circle] < 3 {
+			continue
+		}
+		if line := findLine([]int{circle}, solution[circle]); line != nil {
+			for _, i := range line {
+				used[i] = true
+			}
+			arrows = append(arrows, arrow{circle: circle, cells: line[1:]})
+		}
+	}
+	return arrows
+}
@@ -0,0 +1,143 @@
+package main
+
+import "reflect"
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func sign(x int) int {
+	if x < 0 {
+		return -1
+	}
+	if x > 0 {
+		return 1
+	}
+	return 0
+}
+
+// board holds the fields in columns, b[x][y] is the field in column x and
+// row y.
+type board [][]field
+
+func newBoard(size int) board {
+	b := make(board, size)
+	for x := range b {
+		b[x] = make([]field, size)
+	}
+	return b
+}
+
+// givensBoard returns a board with the givens of g as fixed fields.
+func givensBoard(g grid) board {
+	b := newBoard(g.size())
+	for i, n := range g {
+		b[i%len(b)][i/len(b)].number = n
+		b[i%len(b)][i/len(b)].fixed = n != 0
+	}
+	return b
+}
+
+// givens returns the game with only the fixed numbers.
+func (b board) givens() grid {
+	g := newGrid(len(b))
+	for y := range b {
+		for x := range b {
+			if b[x][y].fixed {
+				g[x+len(b)*y] = b[x][y].number
+			}
+		}
+	}
+	return g
+}
+
+// game returns the numbers on the board.
+func (b board) game() grid {
+	g := newGrid(len(b))
+	for y := range b {
+		for x := range b {
+			g[x+len(b)*y] = b[x][y].number
+		}
+	}
+	return g
+}
+
+// clone returns a copy of b which can be changed without changing b.
+func (b board) clone() board {
+	c := newBoard(len(b))
+	for x := range b {
+		copy(c[x], b[x])
+	}
+	return c
+}
+
+// equals returns true if both boards have the same fields. The selection does
+// not matter.
+func (b board) equals(c board) bool {
+	if len(b) != len(c) {
+		return false
+	}
+	for x := range b {
+		for y := range b {
+			f, g := b[x][y], c[x][y]
+			f.hot, g.hot = false, false
+			if f != g {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// field is a cell on the board. It has room for the pencil marks of the
+// largest shape, there are only 9 colors in every shape.
+type field struct {
+	number int
+	corner [16]bool
+	center [16]bool
+	colors [9]bool
+	hot    bool
+	fixed  bool
+}
+
+// undoHistory has the boards and rules from before each change, the last
+// change comes last. Only setter mode changes the rules.
+type undoHistory []gameState
+
+// gameState is what a change can touch.
+type gameState struct {
+	board board
+	rules rules
+}
+
+// change starts a change of the board and rules that b and r point to and
+// returns the function to call when the change is done. The state from before
+// is added to the history, unless the change did not touch the fields or what
+// the rules draw.
+func (h *undoHistory) change(b *board, r *rules) func() {
+	before := gameState{board: b.clone(), rules: *r}
+	return func() {
+		if !before.board.equals(*b) || !reflect.DeepEqual(before.rules.hints(), r.hints()) {
+			*h = append(*h, before)
+		}
+	}
+}
+
+// undo takes back the last change of the board and rules that b and r point
+// to. The selection stays as it is. Without changes, nothing happens.
+func (h *undoHistory) undo(b *board, r *rules) {
+	if len(*h) == 0 {
+		return
+	}
+	last := (*h)[len(*h)-1]
+	*h = (*h)[:len(*h)-1]
+	for x := range last.board {
+		for y := range last.board {
+			last.board[x][y].hot = (*b)[x][y].hot
+		}
+	}
+	*b, *r = last.board, last.rules
+}
@@ -0,0 +1,181 @@
+package main
+
+import "fmt"
+
+// canonicalForm returns the representative of all games that g can be
+// transformed into, see transform. Two games are equivalent if and only if
+// they have the same canonical form so it can be used to find duplicates in a
+// collection of games.
+//
+// The canonical form is the smallest of all transformed games, when comparing
+// them cell by cell in reading order, with empty cells being smallest. Like
+// transforms, it is only defined for 9x9 games.
+func canonicalForm(g grid) grid {
+	return canonicalTransform(g).applyGame(g)
+}
+
+// equivalentGames returns true if a can be transformed into b.
+func equivalentGames(a, b grid) bool {
+	return canonicalForm(a).equals(canonicalForm(b))
+}
+
+// gameHistory holds the canonical forms of games, so a game is recognized when
+// it comes back transformed.
+type gameHistory map[string]bool
+
+// add remembers g and returns false if g, or a game equivalent to it, was
+// added before.
+func (h gameHistory) add(g grid) bool {
+	key := fmt.Sprint(canonicalForm(g))
+	if h[key] {
+		return false
+	}
+	h[key] = true
+	return true
+}
+
+// canonicalTransform returns the transform which turns g into its canonical
+// form.
+//
+// For a fixed order of rows and columns, the smallest relabelling of the
+// digits is the one that numbers them in the order of their first appearance.
+// This leaves the 2*1296*1296 geometric transforms to be searched. For every
+// arrangement of the columns, the rows are chosen one after the other and
+// arrangements are dropped as soon as their first rows compare greater than
+// the best game found so far.
+func canonicalTransform(g grid) transform {
+	s := canonicalSearch{game: g, cur: newGrid(9)}
+	lines := linePermutations()
+	for _, transpose := range []bool{false, true} {
+		for _, cols := range lines {
+			s.t.transpose = transpose
+			s.t.cols = cols
+			s.searchRows(0, [10]int{}, 1)
+		}
+	}
+	return s.best
+}
+
+type canonicalSearch struct {
+	game grid
+	// t is the transform under construction, its rows are filled in one by
+	// one.
+	t transform
+	// cur is the transformed game, filled in up to the current row.
+	cur grid
+	// best is the transform producing bestGame, the smallest game so far.
+	best     transform
+	bestGame grid
+	found    bool
+}
+
+// searchRows tries all rows of the original game as row number depth of the
+// transformed game. The digits are relabeled with mapping, where next is the
+// next free label.
+func (s *canonicalSearch) searchRows(depth int, mapping [10]int, next int) {
+	if depth == 9 {
+		if s.found && compareCells(s.cur[:], s.bestGame[:]) >= 0 {
+			return
+		}
+		for n := 1; n <= 9; n++ {
+			if mapping[n] == 0 {
+				mapping[n] = next
+				next++
+			}
+		}
+		s.found = true
+		s.best = s.t
+		s.best.digits = mapping
+		s.bestGame = s.cur.clone()
+		return
+	}
+
+	for r := 0; r < 9; r++ {
+		if !s.canUseRow(depth, r) {
+			continue
+		}
+
+		s.t.rows[depth] = r
+		m, n := mapping, next
+		for col := 0; col < 9; col++ {
+			c, row := s.t.source(col, depth)
+			v := s.game[c+9*row]
+			if v != 0 && m[v] == 0 {
+				m[v] = n
+				n++
+			}
+			s.cur[col+9*depth] = m[v]
+		}
+
+		end := 9 * (depth + 1)
+		if s.found && compareCells(s.cur[:end], s.bestGame[:end]) > 0 {
+			continue
+		}
+		s.searchRows(depth+1, m, n)
+	}
+}
+
+// compareCells returns -1 if a < b, 0 if a == b and 1 if a > b, comparing
+// them lexicographically.
+func compareCells(a, b []int) int {
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// canUseRow returns true if original row r can be placed at row depth without
+// breaking up the bands.
+func (s *canonicalSearch) canUseRow(depth, r int) bool {
+	if depth%3 == 0 {
+		// Start a new band, it must not be used yet.
+		for i := 0; i < depth; i++ {
+			if s.t.rows[i]/3 == r/3 {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Continue the current band.
+	if s.t.rows[depth-1]/3 != r/3 {
+		return false
+	}
+	for i := depth - depth%3; i < depth; i++ {
+		if s.t.rows[i] == r {
+			return false
+		}
+	}
+	return true
+}
+
+// linePermutations returns all 1296 ways to arrange 9 rows (or columns) such
+// that the bands (or stacks) stay intact.
+func linePermutations() [][9]int {
+	perms := [][3]int{
+		{0, 1, 2}, {0, 2, 1}, {1, 0, 2}, {1, 2, 0}, {2, 0, 1}, {2, 1, 0},
+	}
+	var lines [][9]int
+	for _, bands := range perms {
+		for _, a := range perms {
+			for _, b := range perms {
+				for _, c := range perms {
+					within := [3][3]int{a, b, c}
+					var l [9]int
+					for band := 0; band < 3; band++ {
+						for i := 0; i < 3; i++ {
+							l[3*band+i] = 3*bands[band] + within[band][i]
+						}
+					}
+					lines = append(lines, l)
+				}
+			}
+		}
+	}
+	return lines
+}
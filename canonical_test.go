@@ -0,0 +1,88 @@
+package main
+
+import "testing"
+
+func TestTransformedGamesAreEquivalent(t *testing.T) {
+	g := parseTestGrid(t, uniqueGame)
+	canonical := canonicalForm(g)
+	for i := 0; i < 20; i++ {
+		transformed := randomTransform().applyGame(g)
+		if !equivalentGames(g, transformed) {
+			t.Fatalf("game is not equivalent to its transform %v", transformed)
+		}
+		if c := canonicalForm(transformed); !c.equals(canonical) {
+			t.Fatalf("canonical form of transform is %v, want %v", c, canonical)
+		}
+		if compareCells(canonical, transformed) > 0 {
+			t.Fatalf("canonical form is greater than transform %v", transformed)
+		}
+	}
+}
+
+func TestDifferentGamesAreNotEquivalent(t *testing.T) {
+	games := hardGames(t)
+	// Both pairs have the same number of givens.
+	if equivalentGames(games[0], games[2]) {
+		t.Error("games 0 and 2 are equivalent")
+	}
+	if equivalentGames(games[3], games[4]) {
+		t.Error("games 3 and 4 are equivalent")
+	}
+	solution, err := classicRules().firstSolution(games[3], false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	more := games[3].clone()
+	for i := range more {
+		if more[i] == 0 {
+			more[i] = solution[i]
+			break
+		}
+	}
+	if equivalentGames(games[3], more) {
+		t.Error("game is equivalent to itself with one more given")
+	}
+}
+
+func TestGameHistory(t *testing.T) {
+	h := gameHistory{}
+	g := parseTestGrid(t, uniqueGame)
+	if !h.add(g) {
+		t.Error("first game was played before")
+	}
+	if h.add(disguiseTransform(classicRules()).applyGame(g)) {
+		t.Error("disguised game was not recognized")
+	}
+	if !h.add(hardGames(t)[0]) {
+		t.Error("other game was played before")
+	}
+}
+
+func BenchmarkCanonicalForm(b *testing.B) {
+	games := hardGames(b)
+	for i := 0; i < b.N; i++ {
+		canonicalForm(games[i%len(games)])
+	}
+}
+
+func TestOnly9x9RulesAreSymmetric(t *testing.T) {
+	rotate := rotateTransform()
+	if !classicRules().symmetricUnder(rotate) {
+		t.Error("classic rules are not symmetric under rotation")
+	}
+	if !append(classicRules(), antiKnight(9)).symmetricUnder(rotate) {
+		t.Error("anti-knight rules are not symmetric under rotation")
+	}
+	// Transforms are for 9x9 grids, other sizes must not pretend to work.
+	for _, s := range shapes {
+		if s == classicShape {
+			continue
+		}
+		if shapeRules(s).symmetricUnder(rotate) {
+			t.Errorf("%v rules are symmetric under a 9x9 rotation", s)
+		}
+		if antiKnight(s.size).symmetricUnder(rotate) {
+			t.Errorf("%v anti-knight is symmetric under a 9x9 rotation", s)
+		}
+	}
+}
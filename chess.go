@@ -0,0 +1,103 @@
+package main
+
+// chessConstraint forbids equal digits in cells that are a chess piece's move
+// apart.
+type chessConstraint struct {
+	// size is the size of the grid.
+	size int
+	// moves are the column and row offsets of the piece's moves.
+	moves [][2]int
+}
+
+// antiKnight forbids equal digits a knight's move apart.
+func antiKnight(size int) chessConstraint {
+	return chessConstraint{size: size, moves: [][2]int{
+		{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2},
+	}}
+}
+
+// antiKing forbids equal digits in diagonally adjacent cells. Orthogonally
+// adjacent cells share a row or column anyway.
+func antiKing(size int) chessConstraint {
+	return chessConstraint{size: size, moves: [][2]int{{1, 1}, {1, -1}, {-1, -1}, {-1, 1}}}
+}
+
+// attacked returns the cells that the piece reaches from cell i.
+func (c chessConstraint) attacked(i int) []int {
+	var cells []int
+	for _, m := range c.moves {
+		col, row := i%c.size+m[0], i/c.size+m[1]
+		if 0 <= col && col < c.size && 0 <= row && row < c.size {
+			cells = append(cells, col+c.size*row)
+		}
+	}
+	return cells
+}
+
+func (c chessConstraint) houses() [][]int {
+	return nil
+}
+
+func (c chessConstraint) allows(g grid, i, n int) bool {
+	for _, j := range c.attacked(i) {
+		if g[j] == n {
+			return false
+		}
+	}
+	return true
+}
+
+// conflicts returns the pairs of cells a move apart with the same digit.
+func (c chessConstraint) conflicts(g grid) [][]int {
+	var pairs [][]int
+	for i := range g {
+		for _, j := range c.attacked(i) {
+			if i < j && g[i] != 0 && g[i] == g[j] {
+				pairs = append(pairs, []int{i, j})
+			}
+		}
+	}
+	return pairs
+}
+
+// distinctGroups returns every pair of cells a move apart.
+func (c chessConstraint) distinctGroups() [][]int {
+	var pairs [][]int
+	for i := 0; i < c.size*c.size; i++ {
+		for _, j := range c.attacked(i) {
+			if i < j {
+				pairs = append(pairs, []int{i, j})
+			}
+		}
+	}
+	return pairs
+}
+
+func (c chessConstraint) hints() []hint {
+	return nil
+}
+
+// symmetricUnder returns true if t keeps cells which are a move apart, a move
+// apart. This holds for rotations and mirrors but not for swapping rows or
+// columns. Transforms only exist for 9x9 grids, in other grids the piece is
+// never symmetric.
+func (c chessConstraint) symmetricUnder(t transform) bool {
+	if c.size != 9 {
+		return false
+	}
+	n := c.size
+	for i := 0; i < n*n; i++ {
+		col, row := t.target(i%n, i/n)
+		moved := make(map[int]bool)
+		for _, j := range c.attacked(col + n*row) {
+			moved[j] = true
+		}
+		for _, j := range c.attacked(i) {
+			col, row := t.target(j%n, j/n)
+			if !moved[col+n*row] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,269 @@
+package main
+
+// exactCover is an implementation of Knuth's Algorithm X using dancing links.
+// It finds sets of rows such that every primary column is covered by exactly
+// one row and every secondary column is covered by at most one row.
+//
+// The matrix is stored as a flat list of nodes, linked by their indices. Node
+// 0 is the root, nodes 1 to columns are the column headers, all other nodes
+// are the 1s in the matrix.
+type exactCover struct {
+	left, right []int
+	up, down    []int
+	col         []int
+	row         []int
+	// size is the number of 1s left in each column, indexed by header node.
+	size []int
+	// covered tells for each header node whether it was covered already.
+	covered []bool
+	// rowStart is the first node of each row.
+	rowStart []int
+	// solution are the rows chosen so far, in the order they were chosen.
+	solution []int
+	// budget, if positive, is the number of rows that the search may still
+	// try. The search stops once the budget is used up.
+	budget int
+	// hidden tells for each row whether it was taken out by hideRow.
+	hidden []bool
+	// prune, if not nil, is called whenever rows were chosen during the
+	// search. It returns rows which can no longer be part of a solution, they
+	// are hidden until the chosen rows are taken back. Constraints that cannot
+	// be expressed as columns can cut down the search this way.
+	prune func() []int
+}
+
+// newExactCover creates an empty matrix. The primary columns are numbered
+// 0..primary-1, the secondary columns follow after them.
+func newExactCover(primary, secondary int) *exactCover {
+	n := 1 + primary + secondary
+	m := &exactCover{
+		left:    make([]int, n),
+		right:   make([]int, n),
+		up:      make([]int, n),
+		down:    make([]int, n),
+		col:     make([]int, n),
+		row:     make([]int, n),
+		size:    make([]int, n),
+		covered: make([]bool, n),
+	}
+	for i := 0; i < n; i++ {
+		m.up[i] = i
+		m.down[i] = i
+		m.col[i] = i
+		m.row[i] = -1
+		// Only primary columns are linked into the header list, they are the
+		// ones which must be covered.
+		if i <= primary {
+			m.left[i] = (i + primary) % (primary + 1)
+			m.right[i] = (i + 1) % (primary + 1)
+		} else {
+			m.left[i] = i
+			m.right[i] = i
+		}
+	}
+	return m
+}
+
+// addRow adds a row with 1s in the given columns and returns the row's index.
+// Rows are numbered in the order they are added, starting at 0.
+func (m *exactCover) addRow(columns ...int) int {
+	r := len(m.rowStart)
+	first := len(m.col)
+	m.rowStart = append(m.rowStart, first)
+	m.hidden = append(m.hidden, false)
+	for i, c := range columns {
+		header := c + 1
+		n := len(m.col)
+		m.col = append(m.col, header)
+		m.row = append(m.row, r)
+		m.up = append(m.up, m.up[header])
+		m.down = append(m.down, header)
+		m.down[m.up[header]] = n
+		m.up[header] = n
+		m.size[header]++
+		if i == 0 {
+			m.left = append(m.left, n)
+			m.right = append(m.right, n)
+		} else {
+			m.left = append(m.left, n-1)
+			m.right = append(m.right, first)
+			m.right[n-1] = n
+			m.left[first] = n
+		}
+	}
+	return r
+}
+
+// selectRow puts the given row into every solution, it is used for the givens
+// of a puzzle. It returns false if the row conflicts with rows that were
+// selected before.
+func (m *exactCover) selectRow(r int) bool {
+	first := m.rowStart[r]
+	n := first
+	for {
+		if m.covered[m.col[n]] {
+			return false
+		}
+		n = m.right[n]
+		if n == first {
+			break
+		}
+	}
+	n = first
+	for {
+		m.cover(m.col[n])
+		n = m.right[n]
+		if n == first {
+			break
+		}
+	}
+	m.solution = append(m.solution, r)
+	return true
+}
+
+// search calls f with every solution, one after the other, until f returns
+// false or all solutions were found. The rows passed to f are only valid
+// during the call, f must copy them if it wants to keep them.
+func (m *exactCover) search(f func(rows []int) bool) {
+	hidden := m.pruneRows()
+	m.searchFrom(f)
+	m.unhideRows(hidden)
+}
+
+func (m *exactCover) searchFrom(f func(rows []int) bool) bool {
+	if m.right[0] == 0 {
+		return f(m.solution)
+	}
+
+	// Continue with the column that has the fewest 1s left.
+	c := m.right[0]
+	for h := m.right[c]; h != 0; h = m.right[h] {
+		if m.size[h] < m.size[c] {
+			c = h
+		}
+	}
+	if m.size[c] == 0 {
+		return true
+	}
+
+	m.cover(c)
+	goOn := true
+	for n := m.down[c]; n != c && goOn; n = m.down[n] {
+		if m.budget > 0 {
+			m.budget--
+			if m.budget == 0 {
+				goOn = false
+				break
+			}
+		}
+		m.solution = append(m.solution, m.row[n])
+		for j := m.right[n]; j != n; j = m.right[j] {
+			m.cover(m.col[j])
+		}
+		hidden := m.pruneRows()
+		goOn = m.searchFrom(f)
+		m.unhideRows(hidden)
+		for j := m.left[n]; j != n; j = m.left[j] {
+			m.uncover(m.col[j])
+		}
+		m.solution = m.solution[:len(m.solution)-1]
+	}
+	m.uncover(c)
+	return goOn
+}
+
+func (m *exactCover) cover(c int) {
+	m.covered[c] = true
+	m.right[m.left[c]] = m.right[c]
+	m.left[m.right[c]] = m.left[c]
+	for i := m.down[c]; i != c; i = m.down[i] {
+		for j := m.right[i]; j != i; j = m.right[j] {
+			m.up[m.down[j]] = m.up[j]
+			m.down[m.up[j]] = m.down[j]
+			m.size[m.col[j]]--
+		}
+	}
+}
+
+func (m *exactCover) uncover(c int) {
+	for i := m.up[c]; i != c; i = m.up[i] {
+		for j := m.left[i]; j != i; j = m.left[j] {
+			m.size[m.col[j]]++
+			m.up[m.down[j]] = j
+			m.down[m.up[j]] = j
+		}
+	}
+	m.right[m.left[c]] = c
+	m.left[m.right[c]] = c
+	m.covered[c] = false
+}
+
+// pruneRows hides the rows that prune rejects and returns them, so they can be
+// restored with unhideRows.
+func (m *exactCover) pruneRows() []int {
+	if m.prune == nil {
+		return nil
+	}
+	var hidden []int
+	for _, r := range m.prune() {
+		if m.available(r) && !m.hidden[r] {
+			m.hideRow(r)
+			hidden = append(hidden, r)
+		}
+	}
+	return hidden
+}
+
+// unhideRows restores rows hidden by pruneRows, in reverse order.
+func (m *exactCover) unhideRows(rows []int) {
+	for k := len(rows) - 1; k >= 0; k-- {
+		m.unhideRow(rows[k])
+	}
+}
+
+// available returns true if row r does not conflict with the rows chosen so
+// far and is not hidden.
+func (m *exactCover) available(r int) bool {
+	if m.hidden[r] {
+		return false
+	}
+	first := m.rowStart[r]
+	for n := first; ; {
+		if m.covered[m.col[n]] {
+			return false
+		}
+		n = m.right[n]
+		if n == first {
+			return true
+		}
+	}
+}
+
+// hideRow takes an available row out of its columns.
+func (m *exactCover) hideRow(r int) {
+	m.hidden[r] = true
+	first := m.rowStart[r]
+	for n := first; ; {
+		m.up[m.down[n]] = m.up[n]
+		m.down[m.up[n]] = m.down[n]
+		m.size[m.col[n]]--
+		n = m.right[n]
+		if n == first {
+			break
+		}
+	}
+}
+
+func (m *exactCover) unhideRow(r int) {
+	first := m.rowStart[r]
+	for n := m.left[first]; ; {
+		m.size[m.col[n]]++
+		m.up[m.down[n]] = n
+		m.down[m.up[n]] = n
+		if n == first {
+			break
+		}
+		n = m.left[n]
+	}
+	m.hidden[r] = false
+}
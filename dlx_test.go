@@ -0,0 +1,218 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/sudoku"
+)
+
+// allCovers returns every solution of m with the rows of each solution
+// sorted, the solutions in the order they were found.
+func allCovers(m *exactCover) [][]int {
+	var covers [][]int
+	m.search(func(rows []int) bool {
+		cover := append([]int(nil), rows...)
+		sort.Ints(cover)
+		covers = append(covers, cover)
+		return true
+	})
+	return covers
+}
+
+// knuthMatrix is the example from Knuth's dancing links paper. Its only
+// exact cover are the rows 0, 3 and 4.
+func knuthMatrix() *exactCover {
+	m := newExactCover(7, 0)
+	m.addRow(2, 4, 5)
+	m.addRow(0, 3, 6)
+	m.addRow(1, 2, 5)
+	m.addRow(0, 3)
+	m.addRow(1, 6)
+	m.addRow(3, 4, 6)
+	return m
+}
+
+func TestExactCoverFindsOnlyCover(t *testing.T) {
+	covers := allCovers(knuthMatrix())
+	if want := [][]int{{0, 3, 4}}; !reflect.DeepEqual(covers, want) {
+		t.Errorf("got covers %v, want %v", covers, want)
+	}
+}
+
+func TestExactCoverSecondaryColumns(t *testing.T) {
+	// Columns 0 and 1 must be covered, column 2 may be covered at most once.
+	m := newExactCover(2, 1)
+	m.addRow(0, 2)
+	m.addRow(1, 2)
+	m.addRow(0)
+	m.addRow(1)
+	covers := allCovers(m)
+	sort.Slice(covers, func(i, j int) bool {
+		return compareCells(covers[i], covers[j]) < 0
+	})
+	// Rows 0 and 1 together would cover column 2 twice.
+	want := [][]int{{0, 3}, {1, 2}, {2, 3}}
+	if !reflect.DeepEqual(covers, want) {
+		t.Errorf("got covers %v, want %v", covers, want)
+	}
+}
+
+func TestExactCoverSelectRow(t *testing.T) {
+	m := knuthMatrix()
+	if !m.selectRow(3) {
+		t.Fatal("cannot select the first row")
+	}
+	// Row 1 shares columns 0 and 3 with row 3.
+	if m.selectRow(1) {
+		t.Error("conflicting row was selected")
+	}
+	if covers := allCovers(m); !reflect.DeepEqual(covers, [][]int{{0, 3, 4}}) {
+		t.Errorf("got covers %v with row 3 selected", covers)
+	}
+
+	m = knuthMatrix()
+	m.selectRow(2)
+	if covers := allCovers(m); len(covers) != 0 {
+		t.Errorf("got covers %v with row 2 selected, want none", covers)
+	}
+}
+
+func TestExactCoverBudget(t *testing.T) {
+	// Every row covers one of the columns, there are 2*2*2 covers.
+	newMatrix := func() *exactCover {
+		m := newExactCover(3, 0)
+		for c := 0; c < 3; c++ {
+			m.addRow(c)
+			m.addRow(c)
+		}
+		return m
+	}
+	if n := len(allCovers(newMatrix())); n != 8 {
+		t.Fatalf("got %d covers without a budget, want 8", n)
+	}
+
+	m := newMatrix()
+	m.budget = 4
+	n := len(allCovers(m))
+	if n == 0 || n >= 8 {
+		t.Errorf("got %d covers with a budget of 4, want some but not all", n)
+	}
+	if m.budget != 0 {
+		t.Errorf("budget is %d after giving up, want 0", m.budget)
+	}
+
+	// Giving up leaves the matrix intact, searching it again finds
+	// everything.
+	if n := len(allCovers(m)); n != 8 {
+		t.Errorf("got %d covers after giving up, want 8", n)
+	}
+}
+
+func TestExactCoverPrune(t *testing.T) {
+	m := newExactCover(2, 0)
+	m.addRow(0)
+	m.addRow(1)
+	m.addRow(0)
+	m.addRow(1)
+	// Once row 0 is chosen, row 1 cannot be used with it.
+	calls := 0
+	m.prune = func() []int {
+		calls++
+		for _, r := range m.solution {
+			if r == 0 {
+				return []int{1}
+			}
+		}
+		return nil
+	}
+	covers := allCovers(m)
+	sort.Slice(covers, func(i, j int) bool {
+		return compareCells(covers[i], covers[j]) < 0
+	})
+	want := [][]int{{0, 3}, {1, 2}, {2, 3}}
+	if !reflect.DeepEqual(covers, want) {
+		t.Errorf("got covers %v, want %v", covers, want)
+	}
+	if calls == 0 {
+		t.Error("prune was never called")
+	}
+
+	// All rows are back after the search.
+	for r, hidden := range m.hidden {
+		if hidden {
+			t.Errorf("row %d is still hidden", r)
+		}
+	}
+	m.prune = nil
+	if n := len(allCovers(m)); n != 4 {
+		t.Errorf("got %d covers without pruning, want 4", n)
+	}
+}
+
+// hardGames reads the test games from testdata/hard.txt, one per line.
+func hardGames(tb testing.TB) []grid {
+	data, err := os.ReadFile("testdata/hard.txt")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var games []grid
+	for _, line := range strings.Fields(string(data)) {
+		g := newGrid(9)
+		for i, c := range line {
+			if c != '.' {
+				g[i] = parseDigit(c)
+			}
+		}
+		games = append(games, g)
+	}
+	return games
+}
+
+func TestHardGamesMatchOldSolver(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the old solver takes seconds for these games")
+	}
+	for i, g := range hardGames(t) {
+		if n := classicRules().countSolutions(g, 2); n != 1 {
+			t.Errorf("game %d has %d solutions, want 1", i, n)
+			continue
+		}
+		solution, err := classicRules().firstSolution(g, false, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var game sudoku.Game
+		copy(game[:], g)
+		want, err := sudoku.Solve(game)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !solution.equals(want[:]) {
+			t.Errorf("game %d: solutions differ", i)
+		}
+	}
+}
+
+func BenchmarkHardGames(b *testing.B) {
+	games := hardGames(b)
+	b.Run("exactCover", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, g := range games {
+				classicRules().firstSolution(g, false, 0)
+			}
+		}
+	})
+	b.Run("gonutz/sudoku", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, g := range games {
+				var game sudoku.Game
+				copy(game[:], g)
+				sudoku.Solve(game)
+			}
+		}
+	})
+}
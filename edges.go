@@ -0,0 +1,258 @@
+package main
+
+import "math/rand"
+
+// edgeKind is a kind of clue on the border between two neighboring cells.
+type edgeKind int
+
+const (
+	noEdgeClue edgeKind = iota
+	// whiteDot joins consecutive digits.
+	whiteDot
+	// blackDot joins digits where one is twice the other.
+	blackDot
+	// xClue joins digits that add up to 10.
+	xClue
+	// vClue joins digits that add up to 5.
+	vClue
+	// greaterThan says that the digit in the first cell is larger than the
+	// one in the second cell, lessThan says it is smaller.
+	greaterThan
+	lessThan
+)
+
+// fits returns true if the digits a and b may be on either side of the clue,
+// a in the first and b in the second cell.
+func (k edgeKind) fits(a, b int) bool {
+	switch k {
+	case whiteDot:
+		return a-b == 1 || b-a == 1
+	case blackDot:
+		return a == 2*b || b == 2*a
+	case xClue:
+		return a+b == 10
+	case vClue:
+		return a+b == 5
+	case greaterThan:
+		return a > b
+	case lessThan:
+		return a < b
+	}
+	return false
+}
+
+// reversed returns the clue seen from the second cell.
+func (k edgeKind) reversed() edgeKind {
+	switch k {
+	case greaterThan:
+		return lessThan
+	case lessThan:
+		return greaterThan
+	}
+	return k
+}
+
+// edgeClue is a clue on the border between cells a and b, which are
+// neighbors.
+type edgeClue struct {
+	a, b int
+	kind edgeKind
+}
+
+// edgeConstraint holds the clues on the borders of a Kropki or XV sudoku.
+type edgeConstraint struct {
+	clues []edgeClue
+	// allGiven are the kinds of clues which are given wherever they fit. This
+	// is the negative constraint: two neighbors without a clue between them
+	// must not fit any of these kinds.
+	allGiven []edgeKind
+	// right[i] is the clue between cells i and i+1, down[i] is the one
+	// between cells i and i+9, both seen from cell i.
+	right, down [81]edgeKind
+}
+
+func newEdgeConstraint(clues []edgeClue, allGiven []edgeKind) *edgeConstraint {
+	c := &edgeConstraint{clues: clues, allGiven: allGiven}
+	for _, clue := range clues {
+		a, b := clue.a, clue.b
+		if a > b {
+			a, b = b, a
+			clue.kind = clue.kind.reversed()
+		}
+		if b == a+1 {
+			c.right[a] = clue.kind
+		} else {
+			c.down[a] = clue.kind
+		}
+	}
+	return c
+}
+
+// between returns the clue between neighboring cells i and j, seen from cell
+// i.
+func (c *edgeConstraint) between(i, j int) edgeKind {
+	if i > j {
+		return c.between(j, i).reversed()
+	}
+	if j == i+1 {
+		return c.right[i]
+	}
+	return c.down[i]
+}
+
+func (c *edgeConstraint) houses() [][]int {
+	return nil
+}
+
+func (c *edgeConstraint) allows(g grid, i, n int) bool {
+	for _, j := range neighbors(i) {
+		kind := c.between(i, j)
+		if g[j] != 0 {
+			if !c.fits(kind, n, g[j]) {
+				return false
+			}
+			continue
+		}
+		if kind == noEdgeClue {
+			continue
+		}
+		// The neighbor shares a row or column so its digit is not n.
+		possible := false
+		for m := 1; m <= 9 && !possible; m++ {
+			possible = m != n && kind.fits(n, m)
+		}
+		if !possible {
+			return false
+		}
+	}
+	return true
+}
+
+// fits returns true if the digits a and b may be on either side of a border
+// with the given clue, which might be noEdgeClue.
+func (c *edgeConstraint) fits(kind edgeKind, a, b int) bool {
+	if kind != noEdgeClue {
+		return kind.fits(a, b)
+	}
+	for _, k := range c.allGiven {
+		if k.fits(a, b) {
+			return false
+		}
+	}
+	return true
+}
+
+// conflicts returns the pairs of neighbors whose digits do not fit the clue
+// between them, or fit a clue which is missing between them.
+func (c *edgeConstraint) conflicts(g grid) [][]int {
+	var pairs [][]int
+	for i := range g {
+		for _, j := range []int{i + 1, i + 9} {
+			if indexOf(neighbors(i), j) == -1 || g[i] == 0 || g[j] == 0 {
+				continue
+			}
+			if !c.fits(c.between(i, j), g[i], g[j]) {
+				pairs = append(pairs, []int{i, j})
+			}
+		}
+	}
+	return pairs
+}
+
+func (c *edgeConstraint) hints() []hint {
+	var hints []hint
+	for _, clue := range c.clues {
+		h := hint{cells: []int{clue.a, clue.b}}
+		switch clue.kind {
+		case whiteDot:
+			h.kind = whiteDotHint
+		case blackDot:
+			h.kind = blackDotHint
+		case xClue:
+			h.kind, h.text = edgeTextHint, "X"
+		case vClue:
+			h.kind, h.text = edgeTextHint, "V"
+		case greaterThan:
+			h.kind = greaterHint
+		case lessThan:
+			h.kind = greaterHint
+			h.cells = []int{clue.b, clue.a}
+		}
+		hints = append(hints, h)
+	}
+	return hints
+}
+
+// symmetricUnder returns true if t moves every clue onto a clue of the same
+// kind, in the same direction. The digits must stay the same, relabeling them
+// breaks the clues.
+func (c *edgeConstraint) symmetricUnder(t transform) bool {
+	if t.digits != identityTransform().digits {
+		return false
+	}
+	for _, clue := range c.clues {
+		a, b := clue.a, clue.b
+		colA, rowA := t.target(a%9, a/9)
+		colB, rowB := t.target(b%9, b/9)
+		a, b = colA+9*rowA, colB+9*rowB
+		if indexOf(neighbors(a), b) == -1 || c.between(a, b) != clue.kind {
+			return false
+		}
+	}
+	return true
+}
+
+// newEdgeGame creates a newGame function for sudokus with the given kinds of
+// clues between cells. If allGiven is true, every clue that fits the solution
+// is given and the negative constraint applies. Otherwise the clues which are
+// not needed for a unique solution are removed after the givens.
+func newEdgeGame(kinds []edgeKind, allGiven bool) func(rules, int) (rules, grid, error) {
+	return func(extra rules, givenDigits int) (rules, grid, error) {
+		r := append(classicRules(), extra...)
+		solution, err := r.randomSolution()
+		if err != nil {
+			return r, solution, err
+		}
+
+		// Put a clue wherever one of the kinds fits, choosing randomly if more
+		// than one does, like a white or black dot between 1 and 2.
+		var clues []edgeClue
+		for i := range solution {
+			for _, j := range []int{i + 1, i + 9} {
+				if indexOf(neighbors(i), j) == -1 {
+					continue
+				}
+				var fitting []edgeKind
+				for _, k := range kinds {
+					if k.fits(solution[i], solution[j]) {
+						fitting = append(fitting, k)
+					}
+				}
+				if len(fitting) > 0 {
+					kind := fitting[rand.Intn(len(fitting))]
+					clues = append(clues, edgeClue{a: i, b: j, kind: kind})
+				}
+			}
+		}
+
+		if allGiven {
+			r = append(r, newEdgeConstraint(clues, kinds))
+			return r, removeGivens(r, solution, givenDigits), nil
+		}
+
+		start := removeGivens(append(r, newEdgeConstraint(clues, nil)), solution, givenDigits)
+		kept := func(dropped []bool) []edgeClue {
+			var kept []edgeClue
+			for k, c := range clues {
+				if !dropped[k] {
+					kept = append(kept, c)
+				}
+			}
+			return kept
+		}
+		dropped := removeClues(start, len(clues), func(dropped []bool) rules {
+			return append(r, newEdgeConstraint(kept(dropped), nil))
+		})
+		return append(r, newEdgeConstraint(kept(dropped), nil)), start, nil
+	}
+}
@@ -0,0 +1,769 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The f-puzzles format is JSON and is what most variant sudokus are shared in,
+// either as files or as links to SudokuPad or f-puzzles which carry the JSON
+// compressed with lz-string. Cells are named like R1C2 for the second cell in
+// the first row. Clues outside the grid are in row or column 0, or one after
+// the last.
+
+// fpuzzle is the part of the f-puzzles JSON that this program understands.
+type fpuzzle struct {
+	Size         int             `json:"size"`
+	Title        string          `json:"title,omitempty"`
+	Grid         [][]fpuzzleCell `json:"grid"`
+	DiagonalUp   bool            `json:"diagonal+,omitempty"`
+	DiagonalDown bool            `json:"diagonal-,omitempty"`
+	AntiKnight   bool            `json:"antiknight,omitempty"`
+	AntiKing     bool            `json:"antiking,omitempty"`
+	// Negative lists the kinds of edge clues which are all given, "ratio"
+	// and "difference" for Kropki dots and "xv" for X and V.
+	Negative     []string      `json:"negative,omitempty"`
+	KillerCage   []fpuzzleClue `json:"killercage,omitempty"`
+	ExtraRegion  []fpuzzleClue `json:"extraregion,omitempty"`
+	Thermometer  []fpuzzleClue `json:"thermometer,omitempty"`
+	Arrow        []fpuzzleClue `json:"arrow,omitempty"`
+	Whispers     []fpuzzleClue `json:"whispers,omitempty"`
+	Renban       []fpuzzleClue `json:"renban,omitempty"`
+	Palindrome   []fpuzzleClue `json:"palindrome,omitempty"`
+	Difference   []fpuzzleClue `json:"difference,omitempty"`
+	Ratio        []fpuzzleClue `json:"ratio,omitempty"`
+	XV           []fpuzzleClue `json:"xv,omitempty"`
+	Sandwich     []fpuzzleClue `json:"sandwichsum,omitempty"`
+	LittleKiller []fpuzzleClue `json:"littlekillersum,omitempty"`
+	Skyscraper   []fpuzzleClue `json:"skyscraper,omitempty"`
+	XSum         []fpuzzleClue `json:"xsum,omitempty"`
+	Even         []fpuzzleClue `json:"even,omitempty"`
+	Odd          []fpuzzleClue `json:"odd,omitempty"`
+}
+
+type fpuzzleCell struct {
+	Value int  `json:"value,omitempty"`
+	Given bool `json:"given,omitempty"`
+	// Region is the 0-based region of the cell, missing means its box.
+	Region *int `json:"region,omitempty"`
+}
+
+// fpuzzleClue is any of the constraints in the lists of fpuzzle. Each kind
+// uses some of the fields.
+type fpuzzleClue struct {
+	Cell      string       `json:"cell,omitempty"`
+	CellStart string       `json:"cellStart,omitempty"`
+	Direction string       `json:"direction,omitempty"`
+	Cells     []string     `json:"cells,omitempty"`
+	Lines     [][]string   `json:"lines,omitempty"`
+	Value     fpuzzleValue `json:"value,omitempty"`
+}
+
+// fpuzzleValue is the value of a clue. It is written as a string but some
+// programs write numbers.
+type fpuzzleValue string
+
+func (v *fpuzzleValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = fpuzzleValue(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*v = fpuzzleValue(n)
+	return nil
+}
+
+// fpuzzleKeys are the keys of the JSON that parseFPuzzle knows, mapped to
+// whether they are allowed for grids other than 9x9. The cosmetic keys are
+// ignored.
+var fpuzzleKeys = map[string]bool{
+	"size": true, "grid": true, "diagonal+": true, "diagonal-": true,
+	"antiknight": true, "antiking": true,
+	"negative": false, "killercage": false, "extraregion": false,
+	"thermometer": false, "arrow": false, "whispers": false, "renban": false,
+	"palindrome": false, "difference": false, "ratio": false, "xv": false,
+	"sandwichsum": false, "littlekillersum": false, "skyscraper": false,
+	"xsum": false, "even": false, "odd": false,
+	// Cosmetic keys.
+	"title": true, "author": true, "ruleset": true, "solution": true,
+	"highlightConflicts": true, "text": true, "line": true, "rectangle": true,
+	"circle": true, "cage": true, "disabledlogic": true,
+	"truecandidatesoptions": true,
+}
+
+// fpuzzleDirections are the directions of little killer clues.
+var fpuzzleDirections = map[string][2]int{
+	"DR": {1, 1}, "DL": {-1, 1}, "UR": {1, -1}, "UL": {-1, -1},
+}
+
+// fpuzzleCellName returns the name of the cell at the 0-based column and row.
+func fpuzzleCellName(col, row int) string {
+	return "R" + strconv.Itoa(row+1) + "C" + strconv.Itoa(col+1)
+}
+
+// fpuzzleCellNames returns the names of cells in a 9x9 grid.
+func fpuzzleCellNames(cells []int) []string {
+	names := make([]string, len(cells))
+	for k, i := range cells {
+		names[k] = fpuzzleCellName(i%9, i/9)
+	}
+	return names
+}
+
+// outsideCellName returns the name of the cell outside the 9x9 grid from
+// which the line is seen, and the direction of the line.
+func outsideCellName(line []int) (string, string) {
+	first, second := line[0], line[1]
+	dc, dr := second%9-first%9, second/9-first/9
+	direction := "D"
+	if dr < 0 {
+		direction = "U"
+	}
+	if dc > 0 {
+		direction += "R"
+	} else {
+		direction += "L"
+	}
+	return fpuzzleCellName(first%9-dc, first/9-dr), direction
+}
+
+// formatFPuzzle writes the givens and the rules of a game in the f-puzzles
+// format. It returns an error for rules which the format cannot express,
+// like samurai games or greater-than signs.
+func formatFPuzzle(givens grid, r rules) (string, error) {
+	s := shapeOfSize(givens.size())
+	if s.samurai {
+		return "", errors.New("samurai games cannot be written in the f-puzzles format")
+	}
+	n := s.size
+
+	jigsaw := r.hasHint(regionHint)
+	regions := r.regions()
+	p := fpuzzle{Size: n}
+	for row := 0; row < n; row++ {
+		cells := make([]fpuzzleCell, n)
+		for col := range cells {
+			i := col + n*row
+			if givens[i] != 0 {
+				cells[col].Value, cells[col].Given = givens[i], true
+			}
+			if jigsaw {
+				region := regions[i]
+				cells[col].Region = &region
+			}
+		}
+		p.Grid = append(p.Grid, cells)
+	}
+
+	standard := make(map[string]bool)
+	for _, c := range shapeRules(s) {
+		for _, house := range c.houses() {
+			standard[cellSetKey(house)] = true
+		}
+	}
+	var down, up []int
+	for i := 0; i < n; i++ {
+		down = append(down, n*i+i)
+		up = append(up, n*i+n-1-i)
+	}
+
+	pathClue := func(cells []int) fpuzzleClue {
+		return fpuzzleClue{Lines: [][]string{fpuzzleCellNames(cells)}}
+	}
+	sideClue := func(clue lineClue) fpuzzleClue {
+		cell, _ := outsideCellName(clue.cells)
+		return fpuzzleClue{Cell: cell, Value: fpuzzleValue(strconv.Itoa(clue.value))}
+	}
+
+	for _, c := range r {
+		switch c := c.(type) {
+		case houseConstraint:
+			for _, house := range c.groups {
+				key := cellSetKey(house)
+				switch {
+				case standard[key]:
+				case key == cellSetKey(down):
+					p.DiagonalDown = true
+				case key == cellSetKey(up):
+					p.DiagonalUp = true
+				case jigsaw && len(c.drawing) > 0 && c.drawing[0].kind == regionHint:
+					// The regions are written with the cells.
+				default:
+					p.ExtraRegion = append(p.ExtraRegion, fpuzzleClue{Cells: fpuzzleCellNames(house)})
+				}
+			}
+		case chessConstraint:
+			if len(c.moves) == len(antiKnight(n).moves) {
+				p.AntiKnight = true
+			} else {
+				p.AntiKing = true
+			}
+		case *killerConstraint:
+			for _, cage := range c.cages {
+				p.KillerCage = append(p.KillerCage, fpuzzleClue{
+					Cells: fpuzzleCellNames(cage.cells),
+					Value: fpuzzleValue(strconv.Itoa(cage.sum)),
+				})
+			}
+		case thermoConstraint:
+			for _, thermo := range c.thermos {
+				p.Thermometer = append(p.Thermometer, pathClue(thermo))
+			}
+		case arrowConstraint:
+			for _, a := range c.arrows {
+				p.Arrow = append(p.Arrow, fpuzzleClue{
+					Cells: fpuzzleCellNames([]int{a.circle}),
+					Lines: [][]string{fpuzzleCellNames(append([]int{a.circle}, a.cells...))},
+				})
+			}
+		case lineConstraint:
+			for _, l := range c.lines {
+				switch l.kind {
+				case whisperLine:
+					p.Whispers = append(p.Whispers, pathClue(l.cells))
+				case renbanLine:
+					p.Renban = append(p.Renban, pathClue(l.cells))
+				case palindromeLine:
+					p.Palindrome = append(p.Palindrome, pathClue(l.cells))
+				}
+			}
+		case *edgeConstraint:
+			for _, clue := range c.clues {
+				e := fpuzzleClue{Cells: fpuzzleCellNames([]int{clue.a, clue.b})}
+				switch clue.kind {
+				case whiteDot:
+					p.Difference = append(p.Difference, e)
+				case blackDot:
+					p.Ratio = append(p.Ratio, e)
+				case xClue, vClue:
+					e.Value = "X"
+					if clue.kind == vClue {
+						e.Value = "V"
+					}
+					p.XV = append(p.XV, e)
+				default:
+					return "", errors.New("greater-than signs cannot be written in the f-puzzles format")
+				}
+			}
+			for _, kind := range c.allGiven {
+				switch kind {
+				case whiteDot:
+					p.Negative = append(p.Negative, "difference")
+				case blackDot:
+					p.Negative = append(p.Negative, "ratio")
+				case xClue:
+					p.Negative = append(p.Negative, "xv")
+				}
+			}
+		case sandwichConstraint:
+			for _, clue := range c.clues {
+				p.Sandwich = append(p.Sandwich, sideClue(clue))
+			}
+		case outsideConstraint:
+			for _, clue := range c.clues {
+				switch c.kind {
+				case littleKillerClue:
+					cell, direction := outsideCellName(clue.cells)
+					p.LittleKiller = append(p.LittleKiller, fpuzzleClue{
+						Cell:      cell,
+						CellStart: fpuzzleCellNames(clue.cells[:1])[0],
+						Direction: direction,
+						Value:     fpuzzleValue(strconv.Itoa(clue.value)),
+					})
+				case skyscraperClue:
+					p.Skyscraper = append(p.Skyscraper, sideClue(clue))
+				case xSumClue:
+					p.XSum = append(p.XSum, sideClue(clue))
+				}
+			}
+		case parityConstraint:
+			for i, marker := range c.markers {
+				switch marker {
+				case even:
+					p.Even = append(p.Even, fpuzzleClue{Cell: fpuzzleCellName(i%9, i/9)})
+				case odd:
+					p.Odd = append(p.Odd, fpuzzleClue{Cell: fpuzzleCellName(i%9, i/9)})
+				}
+			}
+		default:
+			return "", errors.New("the rules cannot be written in the f-puzzles format")
+		}
+	}
+
+	data, err := json.Marshal(p)
+	return string(data), err
+}
+
+// parseFPuzzleCell returns the 0-based column and row of the named cell. They
+// are -1 or 9 for cells outside the 9x9 grid.
+func parseFPuzzleCell(name string) (col, row int, err error) {
+	upper := strings.ToUpper(name)
+	c := strings.IndexByte(upper, 'C')
+	if !strings.HasPrefix(upper, "R") || c == -1 {
+		return 0, 0, errors.New("invalid cell " + name)
+	}
+	row, rowErr := strconv.Atoi(upper[1:c])
+	col, colErr := strconv.Atoi(upper[c+1:])
+	if rowErr != nil || colErr != nil || row < 0 || row > 10 || col < 0 || col > 10 {
+		return 0, 0, errors.New("invalid cell " + name)
+	}
+	return col - 1, row - 1, nil
+}
+
+// parseFPuzzleCells returns the cells inside the 9x9 grid with the given
+// names. If path is true, every cell must touch the one before it.
+func parseFPuzzleCells(names []string, path bool) ([]int, error) {
+	var cells []int
+	for _, name := range names {
+		col, row, err := parseFPuzzleCell(name)
+		if err != nil {
+			return nil, err
+		}
+		if col < 0 || col >= 9 || row < 0 || row >= 9 {
+			return nil, errors.New("cell " + name + " is outside the grid")
+		}
+		i := col + 9*row
+		if path && len(cells) > 0 && indexOf(kingNeighbors(cells[len(cells)-1]), i) == -1 {
+			return nil, errors.New("cells on a line must touch, " + name + " does not")
+		}
+		cells = append(cells, i)
+	}
+	if len(cells) == 0 {
+		return nil, errors.New("a constraint has no cells")
+	}
+	return cells, nil
+}
+
+// fpuzzleLine returns the cells from the given column and row in direction d
+// up to the border of the 9x9 grid.
+func fpuzzleLine(col, row int, d [2]int) []int {
+	var cells []int
+	for ; 0 <= col && col < 9 && 0 <= row && row < 9; col, row = col+d[0], row+d[1] {
+		cells = append(cells, col+9*row)
+	}
+	return cells
+}
+
+// parseSideClue returns the clue for the row or column seen from a cell
+// outside the grid.
+func parseSideClue(clue fpuzzleClue) (lineClue, error) {
+	col, row, err := parseFPuzzleCell(clue.Cell)
+	if err != nil {
+		return lineClue{}, err
+	}
+	var d [2]int
+	switch {
+	case row == -1 && 0 <= col && col < 9:
+		d = [2]int{0, 1}
+	case row == 9 && 0 <= col && col < 9:
+		d = [2]int{0, -1}
+	case col == -1 && 0 <= row && row < 9:
+		d = [2]int{1, 0}
+	case col == 9 && 0 <= row && row < 9:
+		d = [2]int{-1, 0}
+	default:
+		return lineClue{}, errors.New("clue " + clue.Cell + " is not next to a row or column")
+	}
+	value, err := strconv.Atoi(string(clue.Value))
+	if err != nil {
+		return lineClue{}, errors.New("clue " + clue.Cell + " has no number")
+	}
+	return lineClue{cells: fpuzzleLine(col+d[0], row+d[1], d), value: value}, nil
+}
+
+// parseFPuzzle reads a game in the f-puzzles format. Only 9x9 grids can have
+// constraints other than the diagonals and the anti-knight and anti-king
+// rules. Cosmetic parts of the format are ignored, it is an error if the game
+// has any constraint that this program does not know.
+func parseFPuzzle(text string) (grid, rules, error) {
+	var p fpuzzle
+	if err := json.Unmarshal([]byte(text), &p); err != nil {
+		return nil, nil, errors.New("invalid f-puzzles JSON: " + err.Error())
+	}
+	var s shape
+	for _, candidate := range shapes {
+		if p.Size == candidate.size {
+			s = candidate
+		}
+	}
+	if s.size == 0 {
+		return nil, nil, errors.New("the grid must be 4x4, 6x6, 9x9, 12x12 or 16x16")
+	}
+	n := s.size
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &keys); err != nil {
+		return nil, nil, errors.New("invalid f-puzzles JSON: " + err.Error())
+	}
+	for key, value := range keys {
+		switch string(value) {
+		case "false", "null", "[]", `""`:
+			continue
+		}
+		anyShape, known := fpuzzleKeys[key]
+		if !known {
+			return nil, nil, errors.New("unsupported constraint: " + key)
+		}
+		if !anyShape && s != classicShape {
+			return nil, nil, errors.New("only 9x9 games can have the constraint " + key)
+		}
+	}
+
+	if len(p.Grid) != n {
+		return nil, nil, errors.New("the grid must have " + strconv.Itoa(n) + " rows")
+	}
+	givens := newGrid(n)
+	regions := boxRegions()
+	jigsaw := false
+	for row, cells := range p.Grid {
+		if len(cells) != n {
+			return nil, nil, errors.New("every row must have " + strconv.Itoa(n) + " cells")
+		}
+		for col, cell := range cells {
+			i := col + n*row
+			if cell.Given {
+				if cell.Value < 1 || cell.Value > n {
+					return nil, nil, errors.New("invalid digit " + strconv.Itoa(cell.Value))
+				}
+				givens[i] = cell.Value
+			}
+			box := col/s.boxWidth + n/s.boxWidth*(row/s.boxHeight)
+			if cell.Region == nil || *cell.Region == box {
+				continue
+			}
+			if s != classicShape {
+				return nil, nil, errors.New("only 9x9 games can have irregular regions")
+			}
+			if *cell.Region < 0 || *cell.Region >= 9 {
+				return nil, nil, errors.New("invalid region " + strconv.Itoa(*cell.Region))
+			}
+			regions[i] = *cell.Region
+			jigsaw = true
+		}
+	}
+
+	r := shapeRules(s)
+	if jigsaw {
+		var sizes [9]int
+		for _, region := range regions {
+			sizes[region]++
+		}
+		for _, size := range sizes {
+			if size != 9 {
+				return nil, nil, errors.New("every region must have 9 cells")
+			}
+		}
+		r = jigsawRules(regions, nil)
+	}
+
+	var diagonals houseConstraint
+	var down, up []int
+	for i := 0; i < n; i++ {
+		down = append(down, n*i+i)
+		up = append(up, n*i+n-1-i)
+	}
+	if p.DiagonalDown {
+		diagonals.groups = append(diagonals.groups, down)
+		diagonals.drawing = append(diagonals.drawing, hint{kind: lineHint, cells: down})
+	}
+	if p.DiagonalUp {
+		diagonals.groups = append(diagonals.groups, up)
+		diagonals.drawing = append(diagonals.drawing, hint{kind: lineHint, cells: up})
+	}
+	if len(diagonals.groups) > 0 {
+		r = append(r, diagonals)
+	}
+	if p.AntiKnight {
+		r = append(r, antiKnight(n))
+	}
+	if p.AntiKing {
+		r = append(r, antiKing(n))
+	}
+
+	var extra houseConstraint
+	for _, region := range p.ExtraRegion {
+		cells, err := parseFPuzzleCells(region.Cells, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(cells) != 9 {
+			return nil, nil, errors.New("every extra region must have 9 cells")
+		}
+		extra.groups = append(extra.groups, cells)
+		extra.drawing = append(extra.drawing, hint{kind: shadeHint, cells: cells})
+	}
+	if len(extra.groups) > 0 {
+		r = append(r, extra)
+	}
+
+	var cages []cage
+	for _, c := range p.KillerCage {
+		cells, err := parseFPuzzleCells(c.Cells, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		sum, err := strconv.Atoi(string(c.Value))
+		if err != nil {
+			return nil, nil, errors.New("killer cages without a sum are not supported")
+		}
+		cages = append(cages, cage{cells: cells, sum: sum})
+	}
+	if len(cages) > 0 {
+		r = append(r, newKillerConstraint(cages))
+	}
+
+	// eachLine calls f with the cells of every line of the clues.
+	eachLine := func(clues []fpuzzleClue, f func(cells []int) error) error {
+		for _, clue := range clues {
+			for _, names := range clue.Lines {
+				cells, err := parseFPuzzleCells(names, true)
+				if err != nil {
+					return err
+				}
+				if err := f(cells); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	var thermos [][]int
+	err := eachLine(p.Thermometer, func(cells []int) error {
+		thermos = append(thermos, cells)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(thermos) > 0 {
+		r = append(r, thermoConstraint{thermos: thermos})
+	}
+
+	for _, a := range p.Arrow {
+		circle, err := parseFPuzzleCells(a.Cells, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(circle) != 1 {
+			return nil, nil, errors.New("arrows with more than one cell in the circle are not supported")
+		}
+		err = eachLine([]fpuzzleClue{a}, func(cells []int) error {
+			// The line usually starts in the circle.
+			if cells[0] == circle[0] {
+				cells = cells[1:]
+			}
+			if len(cells) == 0 {
+				return errors.New("an arrow has no cells")
+			}
+			r = r.withArrow(arrow{circle: circle[0], cells: cells})
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, w := range p.Whispers {
+		if w.Value != "" && w.Value != "5" {
+			return nil, nil, errors.New("only whispers with a difference of 5 are supported")
+		}
+	}
+	for kind, clues := range [][]fpuzzleClue{p.Whispers, p.Renban, p.Palindrome} {
+		kind := lineKind(kind)
+		err := eachLine(clues, func(cells []int) error {
+			r = r.withLine(line{kind: kind, cells: cells})
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var edges []edgeClue
+	// addEdges adds the clues of a kind, whose values must be one of the
+	// given ones. kindOf returns the kind for a value.
+	addEdges := func(clues []fpuzzleClue, values []string, kindOf func(fpuzzleValue) edgeKind) error {
+		for _, clue := range clues {
+			cells, err := parseFPuzzleCells(clue.Cells, false)
+			if err != nil {
+				return err
+			}
+			if len(cells) != 2 || indexOf(neighbors(cells[0]), cells[1]) == -1 {
+				return errors.New("dots and X and V must be between two neighboring cells")
+			}
+			valid := false
+			for _, value := range values {
+				valid = valid || strings.EqualFold(string(clue.Value), value)
+			}
+			if !valid {
+				return errors.New("unsupported value " + string(clue.Value) + " between cells")
+			}
+			edges = append(edges, edgeClue{a: cells[0], b: cells[1], kind: kindOf(clue.Value)})
+		}
+		return nil
+	}
+	for _, err := range []error{
+		addEdges(p.Difference, []string{"", "1"}, func(fpuzzleValue) edgeKind { return whiteDot }),
+		addEdges(p.Ratio, []string{"", "2"}, func(fpuzzleValue) edgeKind { return blackDot }),
+		addEdges(p.XV, []string{"X", "V"}, func(v fpuzzleValue) edgeKind {
+			if strings.EqualFold(string(v), "V") {
+				return vClue
+			}
+			return xClue
+		}),
+	} {
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	var allGiven []edgeKind
+	for _, kind := range p.Negative {
+		switch kind {
+		case "difference":
+			allGiven = append(allGiven, whiteDot)
+		case "ratio":
+			allGiven = append(allGiven, blackDot)
+		case "xv":
+			allGiven = append(allGiven, xClue, vClue)
+		default:
+			return nil, nil, errors.New("unsupported negative constraint: " + kind)
+		}
+	}
+	if len(edges) > 0 || len(allGiven) > 0 {
+		r = append(r, newEdgeConstraint(edges, allGiven))
+	}
+
+	// sideClues returns the clues of the rows and columns.
+	sideClues := func(clues []fpuzzleClue) ([]lineClue, error) {
+		var lines []lineClue
+		for _, clue := range clues {
+			l, err := parseSideClue(clue)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, l)
+		}
+		return lines, nil
+	}
+	sandwiches, err := sideClues(p.Sandwich)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sandwiches) > 0 {
+		r = append(r, newSandwichConstraint(sandwiches))
+	}
+	for _, kind := range []outsideKind{skyscraperClue, xSumClue} {
+		clues := p.Skyscraper
+		if kind == xSumClue {
+			clues = p.XSum
+		}
+		lines, err := sideClues(clues)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(lines) > 0 {
+			r = append(r, newOutsideConstraint(kind, lines))
+		}
+	}
+
+	var diagonalClues []lineClue
+	for _, clue := range p.LittleKiller {
+		d, ok := fpuzzleDirections[strings.ToUpper(clue.Direction)]
+		if !ok {
+			return nil, nil, errors.New("invalid little killer direction " + clue.Direction)
+		}
+		start := clue.CellStart
+		if start == "" {
+			col, row, err := parseFPuzzleCell(clue.Cell)
+			if err != nil {
+				return nil, nil, err
+			}
+			start = fpuzzleCellName(col+d[0], row+d[1])
+		}
+		col, row, err := parseFPuzzleCell(start)
+		if err != nil {
+			return nil, nil, err
+		}
+		cells := fpuzzleLine(col, row, d)
+		if len(cells) == 0 {
+			return nil, nil, errors.New("little killer clue " + clue.Cell + " points away from the grid")
+		}
+		value, err := strconv.Atoi(string(clue.Value))
+		if err != nil {
+			return nil, nil, errors.New("little killer clue " + clue.Cell + " has no number")
+		}
+		diagonalClues = append(diagonalClues, lineClue{cells: cells, value: value})
+	}
+	if len(diagonalClues) > 0 {
+		r = append(r, newOutsideConstraint(littleKillerClue, diagonalClues))
+	}
+
+	var markers parityConstraint
+	for marker, clues := range map[parity][]fpuzzleClue{even: p.Even, odd: p.Odd} {
+		for _, clue := range clues {
+			cells, err := parseFPuzzleCells([]string{clue.Cell}, false)
+			if err != nil {
+				return nil, nil, err
+			}
+			markers.markers[cells[0]] = marker
+		}
+	}
+	if len(p.Even)+len(p.Odd) > 0 {
+		r = append(r, markers)
+	}
+
+	return givens, r, nil
+}
+
+// fpuzzleLinkPrefix starts the SudokuPad links to games in the f-puzzles
+// format, the compressed JSON follows it.
+const fpuzzleLinkPrefix = "https://sudokupad.app/fpuzzles"
+
+// formatFPuzzleLink returns a SudokuPad link to the game, see formatFPuzzle.
+func formatFPuzzleLink(givens grid, r rules) (string, error) {
+	text, err := formatFPuzzle(givens, r)
+	if err != nil {
+		return "", err
+	}
+	return fpuzzleLinkPrefix + lzCompressToBase64(text), nil
+}
+
+// parseFPuzzleLink reads a game from a SudokuPad or f-puzzles link, or from
+// the f-puzzles JSON itself.
+func parseFPuzzleLink(text string) (grid, rules, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "{") {
+		return parseFPuzzle(text)
+	}
+	data := ""
+	for _, marker := range []string{"fpuzzles", "load="} {
+		if k := strings.Index(text, marker); k != -1 {
+			data = text[k+len(marker):]
+			break
+		}
+	}
+	if data == "" {
+		return nil, nil, errors.New("this is not a SudokuPad or f-puzzles link")
+	}
+	if end := strings.IndexAny(data, "?&#"); end != -1 {
+		data = data[:end]
+	}
+	if unescaped, err := url.PathUnescape(data); err == nil {
+		data = unescaped
+	}
+	// Some programs turn the plus signs of links into spaces.
+	data = strings.ReplaceAll(data, " ", "+")
+	text, err := lzDecompressFromBase64(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseFPuzzle(text)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// emptyFPuzzle is the f-puzzles JSON of an empty 9x9 grid, as f-puzzles
+// writes it.
+var emptyFPuzzle = `{"size":9,"grid":[` +
+	strings.TrimSuffix(strings.Repeat("["+strings.TrimSuffix(strings.Repeat("{},", 9), ",")+"],", 9), ",") +
+	`]}`
+
+func TestLZStringGolden(t *testing.T) {
+	tests := []struct{ text, compressed string }{
+		// An empty text is only the end marker.
+		{"", "Q==="},
+		// Every link to a 9x9 game starts like this one because they all
+		// start with the size and the grid.
+		{emptyFPuzzle, "N4IgzglgXgpiBcBOANCA5gJwgEwQbT2AF9ljSSzKLryBdZQmq8l54+x1p7rjtn/nQaCR3PgIm9hk0UM6zR4rssW0iQA="},
+	}
+	for _, test := range tests {
+		if got := lzCompressToBase64(test.text); got != test.compressed {
+			t.Errorf("%q compressed to %s, want %s", test.text, got, test.compressed)
+		}
+		if test.text == "" {
+			continue
+		}
+		if got, err := lzDecompressFromBase64(test.compressed); err != nil || got != test.text {
+			t.Errorf("%s decompressed to %q, %v, want %q", test.compressed, got, err, test.text)
+		}
+	}
+
+	// Code units above 255 and surrogate pairs are written as 16 bits.
+	for _, text := range []string{"R1C1 é € 😀", strings.Repeat("abc€", 100)} {
+		if got, err := lzDecompressFromBase64(lzCompressToBase64(text)); err != nil || got != text {
+			t.Errorf("%q came back as %q, %v", text, got, err)
+		}
+	}
+}
+
+func TestFPuzzleLinkGolden(t *testing.T) {
+	data := "N4IgzglgXgpiBcBOANCA5gJwgEwQbT2AF9ljSSzKLryBdZQmq8l54+x1p7rjtn/nQaCR3PgIm9hk0UM6zR4rssW0iQA="
+	link, err := formatFPuzzleLink(newGrid(9), classicRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://sudokupad.app/fpuzzles" + data; link != want {
+		t.Errorf("got link %s, want %s", link, want)
+	}
+
+	// The same game is shared in many forms.
+	for _, link := range []string{
+		"https://sudokupad.app/fpuzzles" + data,
+		"  https://sudokupad.app/fpuzzles" + data + "  \n",
+		"https://sudokupad.app/fpuzzles" + strings.ReplaceAll(data, "+", " "),
+		"https://sudokupad.app/fpuzzles" + strings.ReplaceAll(data, "+", "%2B") + "?setting-nogrid=1",
+		"https://www.f-puzzles.com/?load=" + data,
+		"https://www.f-puzzles.com/?load=" + data + "#top",
+		emptyFPuzzle,
+	} {
+		givens, r, err := parseFPuzzleLink(link)
+		if err != nil {
+			t.Errorf("%s: %v", link, err)
+			continue
+		}
+		if !givens.equals(newGrid(9)) || len(r) != len(classicRules()) {
+			t.Errorf("%s: got a different game", link)
+		}
+	}
+
+	for _, link := range []string{
+		"https://sudokupad.app/",
+		"https://sudokupad.app/fpuzzles",
+		"https://sudokupad.app/fpuzzles!!!!",
+	} {
+		if _, _, err := parseFPuzzleLink(link); err == nil {
+			t.Errorf("%s was read", link)
+		}
+	}
+}
+
+func TestFPuzzleKeepsEveryVariant(t *testing.T) {
+	for _, v := range variants {
+		if v.name == "Greater Than" || v.name == "Samurai" {
+			// See TestFPuzzleRejectsUnknownRules.
+			continue
+		}
+		for _, s := range shapes {
+			extras := []rules{nil}
+			if v.name == "Classic" {
+				extras = append(extras, rules{antiKnight(s.size)}, rules{antiKing(s.size)})
+			}
+			for _, extra := range extras {
+				r, givens, err := v.newGame(s, extra, 30)
+				if err != nil {
+					// Most variants only come in 9x9.
+					continue
+				}
+				text, err := formatFPuzzle(givens, r)
+				if err != nil {
+					t.Errorf("%s %v: %v", v.name, s, err)
+					continue
+				}
+				loadedGivens, loaded, err := parseFPuzzle(text)
+				if err != nil {
+					t.Errorf("%s %v: %v in\n%s", v.name, s, err, text)
+					continue
+				}
+				if !loadedGivens.equals(givens) {
+					t.Errorf("%s %v: givens changed", v.name, s)
+				}
+				// The game file format keeps everything, so comparing it
+				// compares the rules.
+				want, err := formatGame(givensBoard(givens), r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := formatGame(givensBoard(loadedGivens), loaded)
+				if err != nil {
+					t.Errorf("%s %v: %v", v.name, s, err)
+					continue
+				}
+				if !reflect.DeepEqual(sortedLines(got), sortedLines(want)) {
+					t.Errorf("%s %v: wrote\n%s\nread back as\n%s", v.name, s, want, got)
+				}
+			}
+		}
+	}
+}
+
+func TestFPuzzleRejectsUnknownRules(t *testing.T) {
+	r := append(classicRules(), newEdgeConstraint([]edgeClue{{a: 0, b: 1, kind: greaterThan}}, nil))
+	if _, err := formatFPuzzle(newGrid(9), r); err == nil {
+		t.Error("greater-than sign was written")
+	}
+	if _, err := formatFPuzzle(newGrid(samuraiShape.size), samuraiRules()); err == nil {
+		t.Error("samurai game was written")
+	}
+	if _, err := formatFPuzzle(newGrid(9), append(classicRules(), unknownConstraint{})); err == nil {
+		t.Error("unknown constraint was written")
+	}
+}
+
+func TestFPuzzleRejectsInvalidJSON(t *testing.T) {
+	for _, text := range []string{
+		``,
+		`{"size":9`,
+		`{"size":9,"grid":[]}`,
+		`{"size":10,"grid":[]}`,
+		`{"size":9,"grid":[],"unknown":[1]}`,
+	} {
+		if _, _, err := parseFPuzzle(text); err == nil {
+			t.Errorf("%q was read", text)
+		}
+	}
+}
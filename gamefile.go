@@ -0,0 +1,579 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// formatGame writes the givens, the rules and the player's progress of a game
+// as text. The first line has the givens in reading order, a dot for an empty
+// cell. Digits above 9 are letters, see digitName. The length of the line
+// tells the size of the grid, the holes of a samurai board are dots. The rows,
+// columns and boxes of the grid are not written.
+//
+// Every other constraint follows on its own line, as its kind, maybe a number
+// and a list of cells. Constraints with cells are only used in 9x9 games.
+// Thermometers start at the bulb and arrows at the circle. Clues outside the
+// grid list the cells of their row, column or diagonal, starting next to the
+// clue. The jigsaw regions are numbered in reading order, like in
+// formatRegions:
+//
+//	diagonal down
+//	diagonal up
+//	antiknight
+//	antiking
+//	regions 111222333111222333...
+//	region r2c2 r2c3 r2c4 r3c2 r3c3 r3c4 r4c2 r4c3 r4c4
+//	thermo r1c1 r2c2 r2c3
+//	arrow r5c5 r5c6 r5c7
+//	whisper r9c1 r8c2 r7c2
+//	cage 15 r1c1 r1c2 r2c1
+//	black r4c4 r4c5
+//	greater r6c1 r7c1
+//	allgiven white black
+//	sandwich 12 r3c1 r3c2 r3c3 r3c4 r3c5 r3c6 r3c7 r3c8 r3c9
+//	littlekiller 23 r1c2 r2c3 r3c4 r4c5 r5c6 r6c7 r7c8 r8c9
+//	even r1c5 r9c9
+//
+// The greater sign points from the larger to the smaller digit. Kinds of clues
+// between cells that are given wherever they fit are listed by allgiven.
+// formatGame returns an error for rules which the format cannot express.
+//
+// The progress is written in layers, each layer on its own line, only if it
+// is not empty. The digits the player entered are written like the givens.
+// The pencil marks and colors have a field for every cell, a dot if the cell
+// has none or the marked digits or colors (1 to 9) in a row:
+//
+//	digits 3.......7...
+//	corner . 12 . 459 ...
+//	center . . 78 . ...
+//	colors 1 . . 26 ...
+func formatGame(b board, r rules) (string, error) {
+	s := shapeOfSize(len(b))
+	n := s.size
+	text := formatDigits(b.givens()) + "\r\n"
+	// Cells can only be named in 9x9 grids.
+	namedCells := false
+	write := func(kind string, cells []int) {
+		text += kind + " " + strings.Join(cellNames(cells), " ") + "\r\n"
+		namedCells = true
+	}
+	writeValue := func(kind string, value int, cells []int) {
+		write(kind+" "+strconv.Itoa(value), cells)
+	}
+
+	standard := make(map[string]bool)
+	for _, c := range shapeRules(s) {
+		for _, house := range c.houses() {
+			standard[cellSetKey(house)] = true
+		}
+	}
+	var down, up []int
+	for i := 0; i < n; i++ {
+		down = append(down, n*i+i)
+		up = append(up, n*i+n-1-i)
+	}
+	jigsaw := r.hasHint(regionHint)
+	if jigsaw {
+		text += "regions " + strings.Join(strings.Fields(formatRegions(r.regions())), "") + "\r\n"
+	}
+	for _, c := range r {
+		switch c := c.(type) {
+		case houseConstraint:
+			for _, house := range c.groups {
+				key := cellSetKey(house)
+				switch {
+				case standard[key]:
+				case key == cellSetKey(down):
+					text += "diagonal down\r\n"
+				case key == cellSetKey(up):
+					text += "diagonal up\r\n"
+				case jigsaw && len(c.drawing) > 0 && c.drawing[0].kind == regionHint:
+					// The regions were written above.
+				default:
+					write("region", house)
+				}
+			}
+		case chessConstraint:
+			if len(c.moves) == len(antiKnight(n).moves) {
+				text += "antiknight\r\n"
+			} else {
+				text += "antiking\r\n"
+			}
+		case *killerConstraint:
+			for _, cage := range c.cages {
+				writeValue("cage", cage.sum, cage.cells)
+			}
+		case thermoConstraint:
+			for _, thermo := range c.thermos {
+				write("thermo", thermo)
+			}
+		case arrowConstraint:
+			for _, a := range c.arrows {
+				write("arrow", append([]int{a.circle}, a.cells...))
+			}
+		case lineConstraint:
+			for _, l := range c.lines {
+				write(l.kind.String(), l.cells)
+			}
+		case *edgeConstraint:
+			for _, clue := range c.clues {
+				cells, kind := []int{clue.a, clue.b}, clue.kind
+				if kind == lessThan {
+					cells, kind = []int{clue.b, clue.a}, greaterThan
+				}
+				name, ok := edgeKindNames[kind]
+				if !ok {
+					return "", errors.New("the rules cannot be written")
+				}
+				write(name, cells)
+			}
+			if len(c.allGiven) > 0 {
+				text += "allgiven"
+				for _, kind := range c.allGiven {
+					text += " " + edgeKindNames[kind]
+				}
+				text += "\r\n"
+			}
+		case sandwichConstraint:
+			for _, clue := range c.clues {
+				writeValue("sandwich", clue.value, clue.cells)
+			}
+		case outsideConstraint:
+			for _, clue := range c.clues {
+				writeValue(outsideKindNames[c.kind], clue.value, clue.cells)
+			}
+		case parityConstraint:
+			var evens, odds []int
+			for i, marker := range c.markers {
+				if marker == even {
+					evens = append(evens, i)
+				} else if marker == odd {
+					odds = append(odds, i)
+				}
+			}
+			if len(evens) > 0 {
+				write("even", evens)
+			}
+			if len(odds) > 0 {
+				write("odd", odds)
+			}
+		default:
+			return "", errors.New("the rules cannot be written")
+		}
+	}
+	if namedCells && s != classicShape {
+		return "", errors.New("only 9x9 games can have constraints with cells")
+	}
+
+	entered := newGrid(len(b))
+	hasEntered := false
+	for y := range b {
+		for x := range b {
+			if !b[x][y].fixed && b[x][y].number != 0 {
+				entered[x+len(b)*y] = b[x][y].number
+				hasEntered = true
+			}
+		}
+	}
+	if hasEntered {
+		text += "digits " + formatDigits(entered) + "\r\n"
+	}
+	layer := func(name string, marks func(f field) []bool) {
+		var fields []string
+		empty := true
+		for y := range b {
+			for x := range b {
+				var f string
+				for i, set := range marks(b[x][y]) {
+					if set {
+						f += digitName(i + 1)
+					}
+				}
+				if f == "" {
+					f = "."
+				} else {
+					empty = false
+				}
+				fields = append(fields, f)
+			}
+		}
+		if !empty {
+			text += name + " " + strings.Join(fields, " ") + "\r\n"
+		}
+	}
+	layer("corner", func(f field) []bool { return f.corner[:] })
+	layer("center", func(f field) []bool { return f.center[:] })
+	layer("colors", func(f field) []bool { return f.colors[:] })
+	return text, nil
+}
+
+// edgeKindNames are the kinds of clues between cells in formatGame. Less-than
+// signs are written as greater-than signs with their cells swapped.
+var edgeKindNames = map[edgeKind]string{
+	whiteDot:    "white",
+	blackDot:    "black",
+	xClue:       "x",
+	vClue:       "v",
+	greaterThan: "greater",
+}
+
+// outsideKindNames are the kinds of clues outside the grid in formatGame.
+var outsideKindNames = map[outsideKind]string{
+	littleKillerClue: "littlekiller",
+	skyscraperClue:   "skyscraper",
+	xSumClue:         "xsum",
+}
+
+// formatDigits writes the digits of g in reading order, a dot for an empty
+// cell.
+func formatDigits(g grid) string {
+	var s string
+	for _, n := range g {
+		if n == 0 {
+			s += "."
+		} else {
+			s += digitName(n)
+		}
+	}
+	return s
+}
+
+// parseGame reads the format written by formatGame. The rules are the classic
+// rules for the size of the grid and the constraints in the text. The board
+// has the givens as fixed fields and the player's progress.
+func parseGame(text string) (board, rules, error) {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+
+	first := strings.TrimSpace(lines[0])
+	var s shape
+	for _, candidate := range append(shapes, samuraiShape) {
+		if len(first) == candidate.size*candidate.size {
+			s = candidate
+		}
+	}
+	if s.size == 0 {
+		return nil, nil, errors.New("the first line must have 16, 36, 81, 144, 256 or, for samurai, 441 cells")
+	}
+	givens, err := parseDigits(first, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	b := givensBoard(givens)
+
+	r := shapeRules(s)
+	// The constraints are collected and added after reading all lines.
+	var (
+		jigsaw     *[81]int
+		diagonals  houseConstraint
+		extra      houseConstraint
+		thermos    [][]int
+		cages      []cage
+		edges      []edgeClue
+		allGiven   []edgeKind
+		sandwiches []lineClue
+		outside    = make(map[outsideKind][]lineClue)
+		markers    parityConstraint
+		hasMarkers bool
+	)
+	for _, text := range lines[1:] {
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "digits":
+			if len(fields) != 2 {
+				return nil, nil, errors.New("the digits need a single line of cells")
+			}
+			entered, err := parseDigits(fields[1], s)
+			if err != nil {
+				return nil, nil, err
+			}
+			for i, n := range entered {
+				f := &b[i%s.size][i/s.size]
+				if n != 0 && f.fixed {
+					return nil, nil, errors.New("a digit was entered into a given cell")
+				}
+				if n != 0 {
+					f.number = n
+				}
+			}
+			continue
+		case "corner", "center", "colors":
+			if len(fields) != 1+len(givens) {
+				return nil, nil, errors.New("the " + fields[0] + " need a field for each of the " + strconv.Itoa(len(givens)) + " cells")
+			}
+			for i, marks := range fields[1:] {
+				f := &b[i%s.size][i/s.size]
+				layer := f.corner[:s.digits()]
+				if fields[0] == "center" {
+					layer = f.center[:s.digits()]
+				} else if fields[0] == "colors" {
+					layer = f.colors[:]
+				}
+				if marks == "." {
+					continue
+				}
+				for _, c := range marks {
+					n := parseDigit(c)
+					if n < 1 || n > len(layer) {
+						return nil, nil, errors.New("invalid mark " + string(c) + " in the " + fields[0])
+					}
+					layer[n-1] = true
+				}
+			}
+			continue
+		case "antiknight", "antiking":
+			if len(fields) != 1 {
+				return nil, nil, errors.New("unknown constraint: " + strings.TrimSpace(text))
+			}
+			if fields[0] == "antiknight" {
+				r = append(r, antiKnight(s.size))
+			} else {
+				r = append(r, antiKing(s.size))
+			}
+			continue
+		case "diagonal":
+			if len(fields) != 2 || fields[1] != "down" && fields[1] != "up" {
+				return nil, nil, errors.New("a diagonal goes either down or up")
+			}
+			var cells []int
+			for k := 0; k < s.size; k++ {
+				if fields[1] == "down" {
+					cells = append(cells, s.size*k+k)
+				} else {
+					cells = append(cells, s.size*k+s.size-1-k)
+				}
+			}
+			diagonals.groups = append(diagonals.groups, cells)
+			diagonals.drawing = append(diagonals.drawing, hint{kind: lineHint, cells: cells})
+			continue
+		}
+
+		if s != classicShape {
+			return nil, nil, errors.New("only 9x9 games can have the constraint " + fields[0])
+		}
+		switch fields[0] {
+		case "regions":
+			if len(fields) != 2 {
+				return nil, nil, errors.New("the regions need a single line of cells")
+			}
+			regions, err := parseRegions(fields[1])
+			if err != nil {
+				return nil, nil, err
+			}
+			jigsaw = &regions
+			continue
+		case "allgiven":
+			for _, name := range fields[1:] {
+				kind := edgeKindNamed(name)
+				if kind == noEdgeClue || kind == greaterThan {
+					return nil, nil, errors.New("invalid clue " + name + " for allgiven")
+				}
+				allGiven = append(allGiven, kind)
+			}
+			continue
+		}
+
+		// All other constraints have cells, some a number before them.
+		args := fields[1:]
+		value := 0
+		switch fields[0] {
+		case "cage", "sandwich", "littlekiller", "skyscraper", "xsum":
+			if len(args) == 0 {
+				return nil, nil, errors.New("a " + fields[0] + " needs a number")
+			}
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return nil, nil, errors.New("a " + fields[0] + " needs a number, not " + args[0])
+			}
+			value, args = n, args[1:]
+		}
+		if len(args) == 0 {
+			return nil, nil, errors.New("a " + fields[0] + " needs cells")
+		}
+		var cells []int
+		for _, name := range args {
+			i, err := parseCellName(name)
+			if err != nil {
+				return nil, nil, err
+			}
+			if indexOf(cells, i) != -1 {
+				return nil, nil, errors.New(fields[0] + " has cell " + name + " twice")
+			}
+			cells = append(cells, i)
+		}
+		// touching checks the cells of paths.
+		touching := func() error {
+			if len(cells) < 2 {
+				return errors.New("a " + fields[0] + " needs at least 2 cells")
+			}
+			for k := 1; k < len(cells); k++ {
+				if indexOf(kingNeighbors(cells[k-1]), cells[k]) == -1 {
+					return errors.New(fields[0] + " cells must touch, " + args[k] + " does not")
+				}
+			}
+			return nil
+		}
+
+		kind := lineKind(0)
+		for kind < lineKindCount && kind.String() != fields[0] {
+			kind++
+		}
+		switch {
+		case fields[0] == "thermo" || fields[0] == "arrow" || kind < lineKindCount:
+			if err := touching(); err != nil {
+				return nil, nil, err
+			}
+			if fields[0] == "thermo" {
+				thermos = append(thermos, cells)
+			} else if fields[0] == "arrow" {
+				r = r.withArrow(arrow{circle: cells[0], cells: cells[1:]})
+			} else {
+				r = r.withLine(line{kind: kind, cells: cells})
+			}
+		case fields[0] == "cage":
+			cages = append(cages, cage{cells: cells, sum: value})
+		case fields[0] == "region":
+			if len(cells) != 9 {
+				return nil, nil, errors.New("a region must have 9 cells")
+			}
+			extra.groups = append(extra.groups, cells)
+			extra.drawing = append(extra.drawing, hint{kind: shadeHint, cells: cells})
+		case edgeKindNamed(fields[0]) != noEdgeClue:
+			if len(cells) != 2 || indexOf(neighbors(cells[0]), cells[1]) == -1 {
+				return nil, nil, errors.New("a " + fields[0] + " clue must be between two neighboring cells")
+			}
+			edges = append(edges, edgeClue{a: cells[0], b: cells[1], kind: edgeKindNamed(fields[0])})
+		case fields[0] == "sandwich":
+			if !straightLine(cells, false) {
+				return nil, nil, errors.New("a sandwich clue must have a whole row or column")
+			}
+			sandwiches = append(sandwiches, lineClue{cells: cells, value: value})
+		case fields[0] == "littlekiller" || fields[0] == "skyscraper" || fields[0] == "xsum":
+			kind := littleKillerClue
+			for k, name := range outsideKindNames {
+				if name == fields[0] {
+					kind = k
+				}
+			}
+			if !straightLine(cells, kind == littleKillerClue) {
+				return nil, nil, errors.New("the cells of a " + fields[0] + " clue must go from one side of the grid to the other")
+			}
+			outside[kind] = append(outside[kind], lineClue{cells: cells, value: value})
+		case fields[0] == "even" || fields[0] == "odd":
+			for _, i := range cells {
+				markers.markers[i] = even
+				if fields[0] == "odd" {
+					markers.markers[i] = odd
+				}
+			}
+			hasMarkers = true
+		default:
+			return nil, nil, errors.New("unknown constraint: " + strings.TrimSpace(text))
+		}
+	}
+
+	if jigsaw != nil {
+		r = append(jigsawRules(*jigsaw, nil), r[3:]...)
+	}
+	if len(diagonals.groups) > 0 {
+		r = append(r, diagonals)
+	}
+	if len(extra.groups) > 0 {
+		r = append(r, extra)
+	}
+	if len(thermos) > 0 {
+		r = append(r, thermoConstraint{thermos: thermos})
+	}
+	if len(cages) > 0 {
+		r = append(r, newKillerConstraint(cages))
+	}
+	if len(edges) > 0 || len(allGiven) > 0 {
+		r = append(r, newEdgeConstraint(edges, allGiven))
+	}
+	if len(sandwiches) > 0 {
+		r = append(r, newSandwichConstraint(sandwiches))
+	}
+	for _, kind := range []outsideKind{littleKillerClue, skyscraperClue, xSumClue} {
+		if len(outside[kind]) > 0 {
+			r = append(r, newOutsideConstraint(kind, outside[kind]))
+		}
+	}
+	if hasMarkers {
+		r = append(r, markers)
+	}
+	return b, r, nil
+}
+
+// edgeKindNamed returns the kind of clue between cells with the given name in
+// edgeKindNames, or noEdgeClue.
+func edgeKindNamed(name string) edgeKind {
+	for kind, n := range edgeKindNames {
+		if n == name {
+			return kind
+		}
+	}
+	return noEdgeClue
+}
+
+// straightLine returns true if the cells go in a straight line from one side
+// of the 9x9 grid to the other, diagonally or along a row or column.
+func straightLine(cells []int, diagonal bool) bool {
+	if len(cells) < 2 {
+		return false
+	}
+	col, row := cells[0]%9, cells[0]/9
+	d := [2]int{cells[1]%9 - col, cells[1]/9 - row}
+	if diagonal && abs(d[0])+abs(d[1]) != 2 || !diagonal && abs(d[0])+abs(d[1]) != 1 {
+		return false
+	}
+	if len(fpuzzleLine(col-d[0], row-d[1], d)) > 0 {
+		// The line must start at the border.
+		return false
+	}
+	line := fpuzzleLine(col, row, d)
+	if len(line) != len(cells) {
+		return false
+	}
+	for k := range line {
+		if cells[k] != line[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseGameFile reads a game saved at the given path. Files ending in .json
+// are in the f-puzzles format, see parseFPuzzle, all others in ours.
+func parseGameFile(path, text string) (board, rules, error) {
+	if !strings.EqualFold(filepath.Ext(path), ".json") {
+		return parseGame(text)
+	}
+	givens, r, err := parseFPuzzle(text)
+	if err != nil {
+		return nil, nil, err
+	}
+	return givensBoard(givens), r, nil
+}
+
+// parseDigits reads a line written by formatDigits for a grid of shape s.
+func parseDigits(line string, s shape) (grid, error) {
+	g := newGrid(s.size)
+	if len(line) != len(g) {
+		return nil, errors.New("a line of digits must have " + strconv.Itoa(len(g)) + " cells")
+	}
+	for i, c := range line {
+		n := parseDigit(c)
+		if 1 <= n && n <= s.digits() && !s.hole(i) {
+			g[i] = n
+		} else if c != '.' && c != '0' {
+			return nil, errors.New("invalid digit " + string(c))
+		}
+	}
+	return g, nil
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGameFileKeepsProgress(t *testing.T) {
+	for _, s := range append(shapes, samuraiShape) {
+		r := shapeRules(s)
+		solution, err := r.randomSolution()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Every third cell is given, the others get some progress.
+		givens := solution.clone()
+		for i := range givens {
+			if i%3 != 0 {
+				givens[i] = 0
+			}
+		}
+		b := givensBoard(givens)
+		i := 0
+		for y := range b {
+			for x := range b {
+				f := &b[x][y]
+				if f.fixed || s.hole(x+s.size*y) {
+					continue
+				}
+				switch i % 4 {
+				case 0:
+					f.number = solution[x+s.size*y]
+				case 1:
+					f.corner[0], f.corner[s.digits()-1] = true, true
+				case 2:
+					f.center[1] = true
+				case 3:
+					f.colors[0], f.colors[8] = true, true
+				}
+				i++
+			}
+		}
+		b[1][0].hot = true
+
+		text, err := formatGame(b, r)
+		if err != nil {
+			t.Fatalf("%v: %v", s, err)
+		}
+		loaded, _, err := parseGame(text)
+		if err != nil {
+			t.Fatalf("%v: %v in\n%s", s, err, text)
+		}
+		b[1][0].hot = false
+		if !loaded.equals(b) {
+			t.Errorf("%v: loaded board differs from\n%s", s, text)
+		}
+		if loaded[1][0].hot {
+			t.Errorf("%v: selection was saved", s)
+		}
+	}
+}
+
+func TestGameFileRejectsInvalidProgress(t *testing.T) {
+	givens := "53..7....6..195....98....6.8...6...34..8.3..17...2...6.6....28....419..5....8..79"
+	for _, text := range []string{
+		// A digit in a given cell.
+		givens + "\ndigits 1",
+		givens + "\ndigits 1" + givens[1:],
+		// Too few fields.
+		givens + "\ncorner 1 2 3",
+		// Digits above 9 in a 9x9 game.
+		givens + "\ncenter A" + repeatField(" .", 80),
+		// Only 9 colors.
+		givens + "\ncolors 0" + repeatField(" .", 80),
+	} {
+		if _, _, err := parseGame(text); err == nil {
+			t.Errorf("no error for\n%s", text)
+		}
+	}
+}
+
+func repeatField(f string, n int) string {
+	s := ""
+	for i := 0; i < n; i++ {
+		s += f
+	}
+	return s
+}
+
+func TestUndo(t *testing.T) {
+	b := givensBoard(parseTestGrid(t, uniqueGame))
+	r := classicRules()
+	var h undoHistory
+
+	// Changing only the selection is no change.
+	change := h.change(&b, &r)
+	b[2][0].hot = true
+	change()
+	if len(h) != 0 {
+		t.Fatalf("history has %d changes, want none", len(h))
+	}
+
+	change = h.change(&b, &r)
+	b[2][0].colors[3] = true
+	change()
+	change = h.change(&b, &r)
+	b[2][0].number = 4
+	change()
+	change = h.change(&b, &r)
+	b[2][0].colors[3] = false
+	change()
+
+	b[2][0].hot = false
+	b[3][0].hot = true
+	h.undo(&b, &r)
+	if !b[2][0].colors[3] || b[2][0].number != 4 {
+		t.Error("clearing the color was not undone")
+	}
+	if b[2][0].hot || !b[3][0].hot {
+		t.Error("undo changed the selection")
+	}
+	h.undo(&b, &r)
+	h.undo(&b, &r)
+	if b[2][0].colors[3] || b[2][0].number != 0 {
+		t.Error("not all changes were undone")
+	}
+	before := b.clone()
+	if h.undo(&b, &r); !b.equals(before) {
+		t.Error("undo without history changed the board")
+	}
+}
+
+func TestUndoDrawnRules(t *testing.T) {
+	// Setter mode draws arrows and lines and removes them with a click.
+	tests := []struct {
+		name     string
+		draw     func(rules) rules
+		removeAt func(rules, int) rules
+	}{
+		{
+			"arrow",
+			func(r rules) rules { return r.withArrow(arrow{circle: 0, cells: []int{1, 2}}) },
+			rules.withoutArrowsAt,
+		},
+		{
+			"whisper",
+			func(r rules) rules { return r.withLine(line{kind: whisperLine, cells: []int{0, 1, 2}}) },
+			rules.withoutLinesAt,
+		},
+		{
+			"renban",
+			func(r rules) rules { return r.withLine(line{kind: renbanLine, cells: []int{0, 1, 2}}) },
+			rules.withoutLinesAt,
+		},
+	}
+	for _, test := range tests {
+		b := givensBoard(newGrid(9))
+		r := classicRules()
+		var h undoHistory
+
+		change := h.change(&b, &r)
+		r = test.draw(r)
+		change()
+		// Removing where there is nothing is no change.
+		change = h.change(&b, &r)
+		r = test.removeAt(r, 40)
+		change()
+		change = h.change(&b, &r)
+		r = test.removeAt(r, 1)
+		change()
+		if len(h) != 2 {
+			t.Fatalf("%s: history has %d changes, want 2", test.name, len(h))
+		}
+
+		h.undo(&b, &r)
+		if len(r.hints()) != 1 {
+			t.Errorf("%s: removing was not undone", test.name)
+		}
+		h.undo(&b, &r)
+		if len(r.hints()) != 0 {
+			t.Errorf("%s: drawing was not undone", test.name)
+		}
+	}
+}
+
+// sortedLines returns the lines of text in order, without the line breaks.
+func sortedLines(text string) []string {
+	lines := strings.Fields(strings.ReplaceAll(text, " ", "_"))
+	sort.Strings(lines)
+	return lines
+}
+
+// variantNamed returns the variant with the given name.
+func variantNamed(t *testing.T, name string) variant {
+	for _, v := range variants {
+		if v.name == name {
+			return v
+		}
+	}
+	t.Fatal("no variant named " + name)
+	return variant{}
+}
+
+func TestGameFileKeepsEveryVariant(t *testing.T) {
+	for _, name := range []string{
+		"Classic", "Sudoku X", "Killer", "Jigsaw", "Windoku", "Thermo",
+		"Arrow", "Sandwich", "Little Killer", "Skyscraper", "X-Sums",
+		"Kropki", "Kropki, All Dots Given", "XV", "XV, All Clues Given",
+		"Whispers, Renban, Palindromes", "Even/Odd", "Greater Than",
+		"Samurai",
+	} {
+		v := variantNamed(t, name)
+		for _, s := range []shape{classicShape, shapes[0]} {
+			extra := rules{antiKing(s.size)}
+			if v.name == "Samurai" {
+				extra = nil
+			}
+			r, givens, err := v.newGame(s, extra, 30)
+			if err != nil {
+				// Most variants only come in 9x9.
+				continue
+			}
+			text, err := formatGame(givensBoard(givens), r)
+			if err != nil {
+				t.Errorf("%s %v: %v", v.name, s, err)
+				continue
+			}
+			b, loaded, err := parseGame(text)
+			if err != nil {
+				t.Errorf("%s %v: %v in\n%s", v.name, s, err, text)
+				continue
+			}
+			if !b.givens().equals(givens) {
+				t.Errorf("%s %v: givens changed", v.name, s)
+			}
+			again, err := formatGame(b, loaded)
+			if err != nil {
+				t.Errorf("%s %v: %v", v.name, s, err)
+				continue
+			}
+			if !reflect.DeepEqual(sortedLines(again), sortedLines(text)) {
+				t.Errorf("%s %v: written as\n%s\nthen as\n%s", v.name, s, text, again)
+			}
+			if len(loaded.hints()) != len(r.hints()) {
+				t.Errorf("%s %v: %d hints, want %d", v.name, s, len(loaded.hints()), len(r.hints()))
+			}
+			if n := loaded.countSolutions(givens, 2); n != 1 {
+				t.Errorf("%s %v: %d solutions, want 1", v.name, s, n)
+			}
+		}
+	}
+}
+
+// unknownConstraint is a constraint that formatGame does not know.
+type unknownConstraint struct{ chessConstraint }
+
+func TestGameFileRejectsUnknownRules(t *testing.T) {
+	b := givensBoard(parseTestGrid(t, uniqueGame))
+	if _, err := formatGame(b, append(classicRules(), unknownConstraint{})); err == nil {
+		t.Error("unknown constraint was written")
+	}
+
+	// Cells cannot be named outside of 9x9 grids.
+	small := givensBoard(newGrid(4))
+	r := append(shapeRules(shapes[0]), thermoConstraint{thermos: [][]int{{0, 1}}})
+	if _, err := formatGame(small, r); err == nil {
+		t.Error("thermometer in a 4x4 game was written")
+	}
+	if _, _, err := parseGame("................\nthermo r1c1 r1c2"); err == nil {
+		t.Error("thermometer in a 4x4 game was read")
+	}
+}
+
+func TestGameFileIgnoresBlankLines(t *testing.T) {
+	givens := newGrid(4)
+	givens[0] = 1
+	small := givensBoard(givens)
+	text, err := formatGame(small, append(shapeRules(shapes[0]), antiKing(4)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Files edited by hand often end in empty lines or use Windows line
+	// endings. Neither must count as a constraint of the 4x4 game.
+	text = strings.Replace(text, "\n", "\r\n\r\n", -1) + "\r\n  \r\n\t\n\n"
+	loaded, r, err := parseGame(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.equals(small) {
+		t.Error("board changed")
+	}
+	if len(r) != len(shapeRules(shapes[0]))+1 {
+		t.Errorf("got %d rules, want the 4x4 houses and anti-king", len(r))
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import "math/rand"
+
+// generateNewGame creates a random game for the given rules, with at least
+// givenDigits digits given. It has a unique solution.
+func generateNewGame(r rules, givenDigits int) (grid, error) {
+	solution, err := r.randomSolution()
+	if err != nil {
+		return solution, err
+	}
+	return removeGivens(r, solution, givenDigits), nil
+}
+
+// removeGivens removes digits from the solution, in random order, while the
+// game stays uniquely solvable under the rules. It stops when only
+// givenDigits digits are left or no more digits can be removed.
+//
+// Proving that a game is unique can take very long for some rules. A digit is
+// kept if this takes too long.
+func removeGivens(r rules, solution grid, givenDigits int) grid {
+	start := solution.clone()
+	want := givenDigits
+
+	// Holes in the board are empty in the solution already.
+	var rest []int
+	for i, n := range start {
+		if n != 0 {
+			rest = append(rest, i)
+		}
+	}
+	have := len(rest)
+
+	for len(rest) > 0 && have != want {
+		n := rand.Intn(len(rest))
+		i := rest[n]
+		was := start[i]
+		start[i] = 0
+		if r.uniqueWithin(start, 20000) {
+			have--
+		} else {
+			start[i] = was
+		}
+		rest[0], rest[n] = rest[n], rest[0]
+		rest = rest[1:]
+	}
+
+	return start
+}
+
+// removeClues decides which of the n clues of a game with the given start are
+// not needed for a unique solution, trying them in random order. It returns
+// the clues to drop. without returns the rules of the game without the
+// dropped clues.
+//
+// Most clues are decided quickly so every clue is tried with a small budget
+// first. The clues for which the budget ran out are tried again at the end,
+// when others were dropped already, with ever larger budgets until each one
+// is decided. A clue is only kept when the game without it has a second
+// solution, so the clues left are a minimal set.
+func removeClues(start grid, n int, without func(dropped []bool) rules) []bool {
+	dropped := make([]bool, n)
+	// try drops clue k if the game stays unique and returns false if this
+	// could not be decided.
+	try := func(k, budget int) bool {
+		dropped[k] = true
+		unique, decided := without(dropped).decideUnique(start, budget)
+		dropped[k] = unique
+		return decided
+	}
+	var undecided []int
+	for _, k := range rand.Perm(n) {
+		if !try(k, 5000) {
+			undecided = append(undecided, k)
+		}
+	}
+	for budget := 50000; len(undecided) > 0; budget *= 10 {
+		var left []int
+		for _, k := range undecided {
+			if !try(k, budget) {
+				left = append(left, k)
+			}
+		}
+		undecided = left
+	}
+	return dropped
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestRemoveCluesKeepsOnlyNeededClues(t *testing.T) {
+	r, start, err := newSandwichGame(nil, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := r.countSolutions(start, 2); n != 1 {
+		t.Fatalf("game has %d solutions, want 1", n)
+	}
+	last := len(r) - 1
+	clues := r[last].(sandwichConstraint).clues
+	for k := range clues {
+		fewer := append(append([]lineClue(nil), clues[:k]...), clues[k+1:]...)
+		without := append(append(rules(nil), r[:last]...), newSandwichConstraint(fewer))
+		if n := without.countSolutions(start, 2); n != 2 {
+			t.Errorf("the game stays unique without clue %d", k)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import "strconv"
+
+// grid holds the digits of a game in reading order, 0 for an empty cell. A
+// grid of size n has n*n cells and uses the digits 1 to n, except for the
+// samurai board, see samuraiShape.
+type grid []int
+
+func newGrid(size int) grid {
+	return make(grid, size*size)
+}
+
+// size is the number of rows and columns of the grid.
+func (g grid) size() int {
+	n := 0
+	for n*n < len(g) {
+		n++
+	}
+	return n
+}
+
+func (g grid) clone() grid {
+	return append(grid(nil), g...)
+}
+
+func (g grid) equals(h grid) bool {
+	if len(g) != len(h) {
+		return false
+	}
+	for i := range g {
+		if g[i] != h[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// shape is the size of a grid and of its boxes. Boxes are boxWidth columns
+// wide and boxHeight rows high. There are as many boxes as rows, so each box
+// has size cells.
+//
+// A samurai shape is different: its board of size*size cells is made of five
+// overlapping 9x9 grids, see samuraiGrids. The cells between them are holes,
+// which are never filled.
+type shape struct {
+	size, boxWidth, boxHeight int
+	samurai                   bool
+}
+
+// shapes are the grid sizes that games can be played in.
+var shapes = []shape{
+	{size: 4, boxWidth: 2, boxHeight: 2},
+	{size: 6, boxWidth: 3, boxHeight: 2},
+	{size: 9, boxWidth: 3, boxHeight: 3},
+	{size: 12, boxWidth: 4, boxHeight: 3},
+	{size: 16, boxWidth: 4, boxHeight: 4},
+}
+
+// classicShape is the usual 9x9 grid with 3x3 boxes.
+var classicShape = shapeOfSize(9)
+
+// samuraiShape is the board of a samurai sudoku. It is not in shapes because
+// it is a variant of its own, not a size that other variants come in.
+var samuraiShape = shape{size: 21, boxWidth: 3, boxHeight: 3, samurai: true}
+
+// shapeOfSize returns the shape from shapes, or the samurai shape, with the
+// given size. It panics if there is none.
+func shapeOfSize(size int) shape {
+	for _, s := range append(shapes, samuraiShape) {
+		if s.size == size {
+			return s
+		}
+	}
+	panic("no shape for size " + strconv.Itoa(size))
+}
+
+func (s shape) String() string {
+	if s.samurai {
+		return "Samurai"
+	}
+	return strconv.Itoa(s.size) + "x" + strconv.Itoa(s.size)
+}
+
+// digits is the number of digits, which is the size of the grid, or of each of
+// the grids of a samurai.
+func (s shape) digits() int {
+	if s.samurai {
+		return 9
+	}
+	return s.size
+}
+
+// grids returns the top-left column and row of each of the grids which make
+// up the board, they are digits() cells wide.
+func (s shape) grids() [][2]int {
+	if s.samurai {
+		return samuraiGrids
+	}
+	return [][2]int{{0, 0}}
+}
+
+// hole returns true if cell i is not part of any grid of the board.
+func (s shape) hole(i int) bool {
+	col, row := i%s.size, i/s.size
+	for _, g := range s.grids() {
+		if g[0] <= col && col < g[0]+s.digits() && g[1] <= row && row < g[1]+s.digits() {
+			return false
+		}
+	}
+	return true
+}
+
+// digitNames are the names of the digits 1 to 16. Digits above 9 are written
+// as letters so every digit is a single character.
+const digitNames = "123456789ABCDEFG"
+
+// digitName returns the character for digit n, which must be 1 to 16.
+func digitName(n int) string {
+	return digitNames[n-1 : n]
+}
+
+// parseDigit is the inverse of digitName. It returns 0 for characters which
+// are not digits, lower case letters are accepted.
+func parseDigit(c rune) int {
+	if 'a' <= c && c <= 'z' {
+		c += 'A' - 'a'
+	}
+	for i, d := range digitNames {
+		if c == d {
+			return i + 1
+		}
+	}
+	return 0
+}
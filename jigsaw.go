@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Regions assign each cell, numbered like in a grid, to one of the
+// regions 0..8. Every region has 9 cells. In classic sudoku the regions are the
+// boxes, jigsaw sudoku uses irregular but connected regions instead.
+
+// boxRegions returns the regions of the classic sudoku, the 3x3 boxes.
+func boxRegions() [81]int {
+	var regions [81]int
+	for i := range regions {
+		regions[i] = 3*(i/27) + i%9/3
+	}
+	return regions
+}
+
+// jigsawRules are the rules of a sudoku with the given regions instead of
+// boxes. Every row, column and region must contain every digit.
+func jigsawRules(regions [81]int, extra rules) rules {
+	r := classicRules()[:2]
+	groups := make([][]int, 9)
+	for i, region := range regions {
+		groups[region] = append(groups[region], i)
+	}
+	var drawing []hint
+	for _, group := range groups {
+		drawing = append(drawing, hint{kind: regionHint, cells: group})
+	}
+	r = append(r, houseConstraint{groups: groups, drawing: drawing})
+	return append(r, extra...)
+}
+
+// regions returns the regions drawn for the rules. Rules without region
+// hints use the boxes.
+func (r rules) regions() [81]int {
+	regions := boxRegions()
+	region := 0
+	for _, h := range r.hints() {
+		if h.kind == regionHint {
+			for _, i := range h.cells {
+				regions[i] = region
+			}
+			region++
+		}
+	}
+	return regions
+}
+
+// newJigsawGame creates random regions and a game for them.
+func newJigsawGame(extra rules, givenDigits int) (rules, grid, error) {
+	for i := 0; i < 200; i++ {
+		// Not every layout of regions can be filled with digits and for some
+		// it takes very long to find out, try another one in that case.
+		if r, g, err := jigsawGame(randomRegions(), extra, givenDigits, 1); err == nil {
+			return r, g, nil
+		}
+	}
+	return nil, nil, errors.New("no regions were found that work with these rules")
+}
+
+// jigsawGame creates a game for the given regions. Finding a solution for
+// the regions can take long, it is tried with a limited budget as many times
+// as given.
+func jigsawGame(regions [81]int, extra rules, givenDigits, tries int) (rules, grid, error) {
+	r := jigsawRules(regions, extra)
+	for i := 0; i < tries; i++ {
+		if solution, err := r.randomSolutionWithin(20000); err == nil {
+			return r, removeGivens(r, solution, givenDigits), nil
+		}
+	}
+	return r, nil, errors.New("no solution was found for these regions")
+}
+
+// randomRegions starts with the boxes and then repeatedly swaps two cells at
+// the border of two neighboring regions, as long as both regions stay
+// connected.
+func randomRegions() [81]int {
+	regions := boxRegions()
+	for swaps := 0; swaps < 150; {
+		a := rand.Intn(81)
+		next := neighbors(a)
+		b := next[rand.Intn(len(next))]
+		if regions[a] == regions[b] {
+			continue
+		}
+
+		// b moves into the region of a, in exchange a cell of that region
+		// which borders the region of b moves over.
+		from, to := regions[b], regions[a]
+		var candidates []int
+		for c := range regions {
+			if regions[c] == to && c != a && bordersRegion(regions, c, from) {
+				candidates = append(candidates, c)
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		c := candidates[rand.Intn(len(candidates))]
+
+		regions[b], regions[c] = to, from
+		if regionConnected(regions, from) && regionConnected(regions, to) {
+			swaps++
+		} else {
+			regions[b], regions[c] = from, to
+		}
+	}
+	return regions
+}
+
+// bordersRegion returns true if cell i has a neighbor in the given region.
+func bordersRegion(regions [81]int, i, region int) bool {
+	for _, j := range neighbors(i) {
+		if regions[j] == region {
+			return true
+		}
+	}
+	return false
+}
+
+// regionConnected returns true if all cells of the region can be reached from
+// each other by going left, right, up and down within the region.
+func regionConnected(regions [81]int, region int) bool {
+	var reached [81]bool
+	var todo []int
+	count := 0
+	for i := range regions {
+		if regions[i] == region {
+			count++
+			if len(todo) == 0 {
+				todo = append(todo, i)
+				reached[i] = true
+			}
+		}
+	}
+	found := 0
+	for len(todo) > 0 {
+		i := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		found++
+		for _, j := range neighbors(i) {
+			if regions[j] == region && !reached[j] {
+				reached[j] = true
+				todo = append(todo, j)
+			}
+		}
+	}
+	return found == count
+}
+
+// formatRegions writes the regions as 9 lines of 9 digits, the regions are
+// numbered 1 to 9 in the order of their first cell.
+func formatRegions(regions [81]int) string {
+	var label [9]int
+	next := 1
+	var s string
+	for i, region := range regions {
+		if label[region] == 0 {
+			label[region] = next
+			next++
+		}
+		s += strconv.Itoa(label[region])
+		if i%9 == 8 && i < 80 {
+			s += "\r\n"
+		}
+	}
+	return s
+}
+
+// parseRegions reads regions in the format of formatRegions. Any 9 different
+// characters may be used to name the regions, white space is ignored. The
+// regions must each have 9 connected cells.
+func parseRegions(text string) ([81]int, error) {
+	var regions [81]int
+	names := make(map[rune]int)
+	text = strings.Join(strings.Fields(text), "")
+	if len([]rune(text)) != 81 {
+		return regions, errors.New("the region map must have 81 cells")
+	}
+	var size [9]int
+	for i, r := range []rune(text) {
+		if _, ok := names[r]; !ok {
+			if len(names) == 9 {
+				return regions, errors.New("the region map must have 9 regions")
+			}
+			names[r] = len(names)
+		}
+		regions[i] = names[r]
+		size[regions[i]]++
+	}
+	for region := range size {
+		if size[region] != 9 {
+			return regions, errors.New("every region must have 9 cells")
+		}
+		if !regionConnected(regions, region) {
+			return regions, errors.New("every region must be connected")
+		}
+	}
+	return regions, nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+// cage is a group of cells whose digits must add up to sum. Digits may not
+// repeat within a cage.
+type cage struct {
+	cells []int
+	sum   int
+}
+
+// killerConstraint holds the cages of a killer sudoku.
+type killerConstraint struct {
+	cages []cage
+	// cageOf is the index into cages for each cell, or -1.
+	cageOf [81]int
+}
+
+func newKillerConstraint(cages []cage) *killerConstraint {
+	c := &killerConstraint{cages: cages}
+	for i := range c.cageOf {
+		c.cageOf[i] = -1
+	}
+	for k, cage := range cages {
+		for _, i := range cage.cells {
+			c.cageOf[i] = k
+		}
+	}
+	return c
+}
+
+func (c *killerConstraint) houses() [][]int {
+	return nil
+}
+
+func (c *killerConstraint) distinctGroups() [][]int {
+	var groups [][]int
+	for _, cage := range c.cages {
+		groups = append(groups, cage.cells)
+	}
+	return groups
+}
+
+func (c *killerConstraint) allows(g grid, i, n int) bool {
+	k := c.cageOf[i]
+	if k == -1 {
+		return true
+	}
+
+	var used [10]bool
+	sum, empty := n, 0
+	for _, j := range c.cages[k].cells {
+		if j == i {
+			continue
+		}
+		if g[j] == n {
+			return false
+		}
+		if g[j] == 0 {
+			empty++
+		} else {
+			used[g[j]] = true
+		}
+		sum += g[j]
+	}
+	used[n] = true
+
+	// The empty cells need at least the sum of the smallest and at most the
+	// sum of the largest unused digits.
+	left := c.cages[k].sum - sum
+	min, max := 0, 0
+	for d, count := 1, 0; d <= 9 && count < empty; d++ {
+		if !used[d] {
+			min += d
+			count++
+		}
+	}
+	for d, count := 9, 0; d >= 1 && count < empty; d-- {
+		if !used[d] {
+			max += d
+			count++
+		}
+	}
+	return min <= left && left <= max
+}
+
+func (c *killerConstraint) conflicts(g grid) [][]int {
+	var conflicts [][]int
+	for _, cage := range c.cages {
+		conflicts = append(conflicts, repeatedDigits(g, cage.cells)...)
+
+		var filled []int
+		sum := 0
+		for _, i := range cage.cells {
+			if g[i] != 0 {
+				filled = append(filled, i)
+				sum += g[i]
+			}
+		}
+		full := len(filled) == len(cage.cells)
+		if sum > cage.sum || full && sum != cage.sum {
+			conflicts = append(conflicts, filled)
+		}
+	}
+	return conflicts
+}
+
+func (c *killerConstraint) hints() []hint {
+	var hints []hint
+	for _, cage := range c.cages {
+		hints = append(hints, hint{
+			kind:  cageHint,
+			cells: cage.cells,
+			text:  strconv.Itoa(cage.sum),
+		})
+	}
+	return hints
+}
+
+// symmetricUnder returns true if t moves every cage onto a cage with the same
+// sum. The digits must stay the same, relabeling them changes the sums.
+func (c *killerConstraint) symmetricUnder(t transform) bool {
+	if t.digits != identityTransform().digits {
+		return false
+	}
+	have := make(map[string]bool)
+	for _, cage := range c.cages {
+		have[cellSetKey(cage.cells)+strconv.Itoa(cage.sum)] = true
+	}
+	for _, cage := range c.cages {
+		moved := make([]int, len(cage.cells))
+		for j, i := range cage.cells {
+			col, row := t.target(i%9, i/9)
+			moved[j] = col + 9*row
+		}
+		if !have[cellSetKey(moved)+strconv.Itoa(cage.sum)] {
+			return false
+		}
+	}
+	return true
+}
+
+// cageAt returns the killer cage that cell i is in, if the rules have cages.
+func (r rules) cageAt(i int) (cage, bool) {
+	for _, c := range r {
+		if k, ok := c.(*killerConstraint); ok && k.cageOf[i] != -1 {
+			return k.cages[k.cageOf[i]], true
+		}
+	}
+	return cage{}, false
+}
+
+// newKillerGame generates a killer sudoku. The cages are built from a random
+// solution and then givens are removed as long as the game stays unique, if
+// possible down to none.
+func newKillerGame(extra rules, givenDigits int) (rules, grid, error) {
+	r := append(classicRules(), extra...)
+	solution, err := r.randomSolution()
+	if err != nil {
+		return r, solution, err
+	}
+	r = append(r, newKillerConstraint(randomCages(solution)))
+	return r, removeGivens(r, solution, givenDigits), nil
+}
+
+// randomCages cuts the solution into cages of connected cells. The digits in a
+// cage are all different. Most cages have 2 to 4 cells.
+func randomCages(solution grid) []cage {
+	var inCage [81]bool
+	var cages []cage
+	for _, start := range rand.Perm(81) {
+		if inCage[start] {
+			continue
+		}
+
+		size := []int{1, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 5}[rand.Intn(12)]
+		cells := []int{start}
+		inCage[start] = true
+		sum := solution[start]
+		for len(cells) < size {
+			// Grow the cage by a random neighbor that is not in any cage and
+			// whose digit is not yet in this cage.
+			var candidates []int
+			for _, i := range cells {
+				for _, j := range neighbors(i) {
+					if !inCage[j] && !containsDigit(solution, cells, solution[j]) {
+						candidates = append(candidates, j)
+					}
+				}
+			}
+			if len(candidates) == 0 {
+				break
+			}
+			next := candidates[rand.Intn(len(candidates))]
+			cells = append(cells, next)
+			inCage[next] = true
+			sum += solution[next]
+		}
+		cages = append(cages, cage{cells: cells, sum: sum})
+	}
+	return cages
+}
+
+// neighbors returns the cells left, right, above and below cell i.
+func neighbors(i int) []int {
+	var n []int
+	col, row := i%9, i/9
+	if col > 0 {
+		n = append(n, i-1)
+	}
+	if col < 8 {
+		n = append(n, i+1)
+	}
+	if row > 0 {
+		n = append(n, i-9)
+	}
+	if row < 8 {
+		n = append(n, i+9)
+	}
+	return n
+}
+
+func containsDigit(g grid, cells []int, n int) bool {
+	for _, i := range cells {
+		if g[i] == n {
+			return true
+		}
+	}
+	return false
+}
+
+// cageCombinations returns all sets of count different digits that add up to
+// sum and contain all the digits in must. The sets are sorted, each in
+// increasing order.
+func cageCombinations(sum, count int, must []int) [][]int {
+	var combinations [][]int
+	var digits []int
+	var find func(from, left int)
+	find = func(from, left int) {
+		if len(digits) == count {
+			if left == 0 && containsAll(digits, must) {
+				combinations = append(combinations, append([]int(nil), digits...))
+			}
+			return
+		}
+		for d := from; d <= 9 && d <= left; d++ {
+			digits = append(digits, d)
+			find(d+1, left-d)
+			digits = digits[:len(digits)-1]
+		}
+	}
+	find(1, sum)
+	return combinations
+}
+
+func containsAll(list, want []int) bool {
+	for _, w := range want {
+		found := false
+		for _, x := range list {
+			found = found || x == w
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// lineKind is the rule that a line puts on the digits along it.
+type lineKind int
+
+const (
+	// whisperLine is a German whispers line: neighboring digits on it differ
+	// by at least 5.
+	whisperLine lineKind = iota
+	// renbanLine holds a set of consecutive digits, in any order.
+	renbanLine
+	// palindromeLine reads the same from both ends.
+	palindromeLine
+	lineKindCount
+)
+
+func (k lineKind) String() string {
+	switch k {
+	case whisperLine:
+		return "whisper"
+	case renbanLine:
+		return "renban"
+	case palindromeLine:
+		return "palindrome"
+	}
+	return "lineKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// line is a path of cells, each touching the one before it, with a rule on
+// its digits.
+type line struct {
+	kind  lineKind
+	cells []int
+}
+
+// lineConstraint holds the whispers, renban and palindrome lines of a game.
+type lineConstraint struct {
+	lines []line
+}
+
+func (c lineConstraint) houses() [][]int {
+	return nil
+}
+
+// distinctGroups returns the renban lines, their digits cannot repeat.
+func (c lineConstraint) distinctGroups() [][]int {
+	var groups [][]int
+	for _, l := range c.lines {
+		if l.kind == renbanLine {
+			groups = append(groups, l.cells)
+		}
+	}
+	return groups
+}
+
+func (c lineConstraint) allows(g grid, i, n int) bool {
+	for _, l := range c.lines {
+		at := indexOf(l.cells, i)
+		if at == -1 {
+			continue
+		}
+		switch l.kind {
+		case whisperLine:
+			// A 5 has no digit that is 5 away. Other digits are only
+			// checked against their placed neighbors on the line.
+			if n == 5 {
+				return false
+			}
+			for _, k := range []int{at - 1, at + 1} {
+				if 0 <= k && k < len(l.cells) {
+					m := g[l.cells[k]]
+					if m != 0 && abs(n-m) < 5 {
+						return false
+					}
+				}
+			}
+		case renbanLine:
+			min, max := n, n
+			for _, j := range l.cells {
+				m := g[j]
+				if j == i || m == 0 {
+					continue
+				}
+				if m == n {
+					return false
+				}
+				if m < min {
+					min = m
+				}
+				if m > max {
+					max = m
+				}
+			}
+			if max-min >= len(l.cells) {
+				return false
+			}
+		case palindromeLine:
+			m := g[l.cells[len(l.cells)-1-at]]
+			if m != 0 && m != n {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// conflicts returns the cells on lines whose digits break the line's rule.
+func (c lineConstraint) conflicts(g grid) [][]int {
+	var conflicts [][]int
+	for _, l := range c.lines {
+		switch l.kind {
+		case whisperLine:
+			for k := 1; k < len(l.cells); k++ {
+				i, j := l.cells[k-1], l.cells[k]
+				if g[i] != 0 && g[j] != 0 && abs(g[i]-g[j]) < 5 {
+					conflicts = append(conflicts, []int{i, j})
+				}
+			}
+		case renbanLine:
+			var filled []int
+			min, max := 10, 0
+			for _, i := range l.cells {
+				if g[i] != 0 {
+					filled = append(filled, i)
+					if g[i] < min {
+						min = g[i]
+					}
+					if g[i] > max {
+						max = g[i]
+					}
+				}
+			}
+			if len(filled) > 0 && max-min >= len(l.cells) {
+				conflicts = append(conflicts, filled)
+			}
+			conflicts = append(conflicts, repeatedDigits(g, l.cells)...)
+		case palindromeLine:
+			for k := 0; k < len(l.cells)/2; k++ {
+				i, j := l.cells[k], l.cells[len(l.cells)-1-k]
+				if g[i] != 0 && g[j] != 0 && g[i] != g[j] {
+					conflicts = append(conflicts, []int{i, j})
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+func (c lineConstraint) hints() []hint {
+	var hints []hint
+	for _, l := range c.lines {
+		kind := whisperHint
+		if l.kind == renbanLine {
+			kind = renbanHint
+		} else if l.kind == palindromeLine {
+			kind = palindromeHint
+		}
+		hints = append(hints, hint{kind: kind, cells: l.cells})
+	}
+	return hints
+}
+
+// symmetricUnder returns true if t moves every line onto a line of the same
+// kind, in either direction. The digits must stay the same.
+func (c lineConstraint) symmetricUnder(t transform) bool {
+	if t.digits != identityTransform().digits {
+		return false
+	}
+	key := func(kind lineKind, cells []int) string {
+		return kind.String() + " " + strings.Join(cellNames(cells), " ")
+	}
+	have := make(map[string]bool)
+	for _, l := range c.lines {
+		have[key(l.kind, l.cells)] = true
+	}
+	for _, l := range c.lines {
+		moved := make([]int, len(l.cells))
+		reversed := make([]int, len(l.cells))
+		for j, i := range l.cells {
+			col, row := t.target(i%9, i/9)
+			moved[j] = col + 9*row
+			reversed[len(l.cells)-1-j] = col + 9*row
+		}
+		if !have[key(l.kind, moved)] && !have[key(l.kind, reversed)] {
+			return false
+		}
+	}
+	return true
+}
+
+// withLine returns the rules with the line added to their line constraint,
+// which is created if the rules have none yet.
+func (r rules) withLine(l line) rules {
+	result := append(rules(nil), r...)
+	for k, c := range result {
+		if lines, ok := c.(lineConstraint); ok {
+			result[k] = lineConstraint{
+				lines: append(append([]line(nil), lines.lines...), l),
+			}
+			return result
+		}
+	}
+	return append(result, lineConstraint{lines: []line{l}})
+}
+
+// withoutLinesAt returns the rules without the lines that go through cell i.
+func (r rules) withoutLinesAt(i int) rules {
+	result := append(rules(nil), r...)
+	for k, c := range result {
+		if lines, ok := c.(lineConstraint); ok {
+			var keep []line
+			for _, l := range lines.lines {
+				if indexOf(l.cells, i) == -1 {
+					keep = append(keep, l)
+				}
+			}
+			result[k] = lineConstraint{lines: keep}
+		}
+	}
+	return result
+}
+
+// newLineGame generates a sudoku with whispers, renban and palindrome lines.
+// The lines are laid along digits of a random solution that follow their
+// rules. Then givens are removed as long as the game stays unique.
+func newLineGame(extra rules, givenDigits int) (rules, grid, error) {
+	r := append(classicRules(), extra...)
+	solution, err := r.randomSolution()
+	if err != nil {
+		return r, solution, err
+	}
+	r = append(r, lineConstraint{lines: findLines(solution)})
+	return r, removeGivens(r, solution, givenDigits), nil
+}
+
+// findLines finds up to 3 random lines of each kind in the solution, with 3
+// to 6 cells. Lines do not share cells.
+func findLines(solution grid) []line {
+	var used [81]bool
+	var lines []line
+
+	// fits returns true if the digits on the cells follow the line's rule so
+	// far, and once it has its final length.
+	fits := func(kind lineKind, cells []int, final bool) bool {
+		switch kind {
+		case whisperLine:
+			a, b := solution[cells[len(cells)-1]], solution[cells[len(cells)-2]]
+			return abs(a-b) >= 5
+		case renbanLine:
+			min, max := 10, 0
+			for k, i := range cells {
+				if containsDigit(solution, cells[:k], solution[i]) {
+					return false
+				}
+				if solution[i] < min {
+					min = solution[i]
+				}
+				if solution[i] > max {
+					max = solution[i]
+				}
+			}
+			return !final || max-min == len(cells)-1
+		}
+		return false
+	}
+
+	// grow extends the line to the wanted length.
+	var grow func(kind lineKind, cells []int, want int) []int
+	grow = func(kind lineKind, cells []int, want int) []int {
+		if len(cells) == want {
+			return cells
+		}
+		next := kingNeighbors(cells[len(cells)-1])
+		for _, k := range rand.Perm(len(next)) {
+			j := next[k]
+			if used[j] || indexOf(cells, j) != -1 {
+				continue
+			}
+			longer := append(append([]int(nil), cells...), j)
+			if fits(kind, longer, len(longer) == want) {
+				if found := grow(kind, longer, want); found != nil {
+					return found
+				}
+			}
+		}
+		return nil
+	}
+
+	// growPalindrome extends both ends of the line by cells with equal
+	// digits.
+	growPalindrome := func(center int, want int) []int {
+		cells := []int{center}
+		for len(cells) < want {
+			first, last := cells[0], cells[len(cells)-1]
+			var pairs [][2]int
+			for _, a := range kingNeighbors(first) {
+				for _, b := range kingNeighbors(last) {
+					if a != b && solution[a] == solution[b] && !used[a] && !used[b] &&
+						indexOf(cells, a) == -1 && indexOf(cells, b) == -1 {
+						pairs = append(pairs, [2]int{a, b})
+					}
+				}
+			}
+			if len(pairs) == 0 {
+				return nil
+			}
+			p := pairs[rand.Intn(len(pairs))]
+			cells = append(append([]int{p[0]}, cells...), p[1])
+		}
+		return cells
+	}
+
+	for kind := lineKind(0); kind < lineKindCount; kind++ {
+		count := 0
+		for _, start := range rand.Perm(81) {
+			if count == 3 {
+				break
+			}
+			if used[start] {
+				continue
+			}
+			var cells []int
+			if kind == palindromeLine {
+				cells = growPalindrome(start, []int{3, 5}[rand.Intn(2)])
+			} else {
+				cells = grow(kind, []int{start}, 3+rand.Intn(4))
+			}
+			if cells != nil {
+				for _, i := range cells {
+					used[i] = true
+				}
+				lines = append(lines, line{kind: kind, cells: cells})
+				count++
+			}
+		}
+	}
+	return lines
+}
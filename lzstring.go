@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf16"
+)
+
+// This is the base64 variant of the lz-string compression, which f-puzzles
+// and SudokuPad use for their puzzle links. It works on the UTF-16 code units
+// of the text, like the JavaScript original.
+
+const lzBase64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+
+// lzCompressToBase64 compresses the text the way lz-string's compressToBase64
+// does.
+func lzCompressToBase64(text string) string {
+	var out strings.Builder
+	val, position := 0, 0
+	// write appends the lowest bits of value, lowest first, to the output
+	// of 6 bits per character.
+	write := func(bits, value int) {
+		for i := 0; i < bits; i++ {
+			val = val<<1 | value&1
+			value >>= 1
+			if position == 5 {
+				out.WriteByte(lzBase64[val])
+				val, position = 0, 0
+			} else {
+				position++
+			}
+		}
+	}
+
+	// Strings of code units are used as map keys, two bytes per unit.
+	key := func(units []uint16) string {
+		b := make([]byte, 2*len(units))
+		for i, u := range units {
+			b[2*i], b[2*i+1] = byte(u>>8), byte(u)
+		}
+		return string(b)
+	}
+
+	dictionary := make(map[string]int)
+	toCreate := make(map[string]bool)
+	enlargeIn, dictSize, numBits := 2, 3, 2
+	// grow counts down the codes until the code size has to grow.
+	grow := func() {
+		enlargeIn--
+		if enlargeIn == 0 {
+			enlargeIn = 1 << numBits
+			numBits++
+		}
+	}
+	// emit writes the code for w, a new single unit is written as a marker
+	// code followed by the unit itself.
+	emit := func(w []uint16) {
+		k := key(w)
+		if toCreate[k] {
+			if w[0] < 256 {
+				write(numBits, 0)
+				write(8, int(w[0]))
+			} else {
+				write(numBits, 1)
+				write(16, int(w[0]))
+			}
+			grow()
+			delete(toCreate, k)
+		} else {
+			write(numBits, dictionary[k])
+		}
+		grow()
+	}
+
+	var w []uint16
+	for _, c := range utf16.Encode([]rune(text)) {
+		cKey := key([]uint16{c})
+		if _, ok := dictionary[cKey]; !ok {
+			dictionary[cKey] = dictSize
+			dictSize++
+			toCreate[cKey] = true
+		}
+		wc := append(append([]uint16(nil), w...), c)
+		if _, ok := dictionary[key(wc)]; ok {
+			w = wc
+			continue
+		}
+		emit(w)
+		dictionary[key(wc)] = dictSize
+		dictSize++
+		w = []uint16{c}
+	}
+	if len(w) > 0 {
+		emit(w)
+	}
+
+	// Mark the end of the stream and flush the last character.
+	write(numBits, 2)
+	for {
+		val <<= 1
+		if position == 5 {
+			out.WriteByte(lzBase64[val])
+			break
+		}
+		position++
+	}
+
+	s := out.String()
+	if len(s)%4 != 0 {
+		s += strings.Repeat("=", 4-len(s)%4)
+	}
+	return s
+}
+
+// lzDecompressFromBase64 is the inverse of lzCompressToBase64.
+func lzDecompressFromBase64(text string) (string, error) {
+	invalid := errors.New("invalid compressed text")
+	if text == "" {
+		return "", invalid
+	}
+	// next returns the 6 bits of the character at index, characters after
+	// the text count as 0.
+	next := func(index int) int {
+		if index >= len(text) {
+			return 0
+		}
+		return strings.IndexByte(lzBase64, text[index])
+	}
+	for i := range text {
+		if next(i) == -1 {
+			return "", invalid
+		}
+	}
+
+	val, position, index := next(0), 32, 1
+	read := func(bits int) int {
+		result := 0
+		for power := 1; power < 1<<bits; power <<= 1 {
+			if val&position != 0 {
+				result |= power
+			}
+			position >>= 1
+			if position == 0 {
+				position = 32
+				val = next(index)
+				index++
+			}
+		}
+		return result
+	}
+
+	dictionary := [][]uint16{{0}, {1}, {2}}
+	enlargeIn, numBits := 4, 3
+	var w []uint16
+	switch read(2) {
+	case 0:
+		w = []uint16{uint16(read(8))}
+	case 1:
+		w = []uint16{uint16(read(16))}
+	default:
+		return "", nil
+	}
+	dictionary = append(dictionary, w)
+	result := append([]uint16(nil), w...)
+
+	for {
+		if index > len(text) {
+			return "", invalid
+		}
+		c := read(numBits)
+		switch c {
+		case 0, 1:
+			bits := 8
+			if c == 1 {
+				bits = 16
+			}
+			dictionary = append(dictionary, []uint16{uint16(read(bits))})
+			c = len(dictionary) - 1
+			enlargeIn--
+		case 2:
+			return string(utf16.Decode(result)), nil
+		}
+		if enlargeIn == 0 {
+			enlargeIn = 1 << numBits
+			numBits++
+		}
+
+		var entry []uint16
+		if c < len(dictionary) {
+			entry = dictionary[c]
+		} else if c == len(dictionary) {
+			entry = append(append([]uint16(nil), w...), w[0])
+		} else {
+			return "", invalid
+		}
+		result = append(result, entry...)
+		dictionary = append(dictionary, append(append([]uint16(nil), w...), entry[0]))
+		enlargeIn--
+		w = entry
+		if enlargeIn == 0 {
+			enlargeIn = 1 << numBits
+			numBits++
+		}
+	}
+}
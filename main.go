@@ -1,159 +1,258 @@
+//go:build windows
+
 package main
 
 import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 	"unsafe"
 
-	"github.com/gonutz/sudoku"
 	"github.com/gonutz/w32/v2"
 	"github.com/gonutz/wui/v2"
 )
 
 var (
-	tileSize         = 90
-	thinBorderSize   = 3
-	thickBorderSize  = 3 * thinBorderSize
-	boardSize        = 4*thickBorderSize + 6*thinBorderSize + 9*tileSize
-	mediumFontHeight = tileSize / 2
-
-	backColor          = wui.RGB(64, 64, 64)
-	hotColor           = wui.RGB(64, 64, 192)
-	borderColor        = wui.RGB(192, 192, 192)
-	textColor          = wui.RGB(255, 255, 255)
-	fixedColor         = wui.RGB(192, 192, 255)
-	highlightBackColor = wui.RGB(92, 92, 64)
+	// scrollX and scrollY are how far the board and panel are scrolled to the
+	// left and up when they are larger than the window.
+	scrollX = 0
+	scrollY = 0
+
+	// zoomedTileSize is the tile size after zooming in further than the
+	// window can grow, or 0 when the tiles follow the window size.
+	zoomedTileSize = 0
 
 	gameMode = false
 )
 
+const helpText = `
+F1 - Help On/Off
+F2 - New Game
+F3 - Rotate Clockwise (9x9 Only)
+F4/Shift+F4 - Mirror Left-Right/Top-Bottom (9x9 Only)
+F5 - Mirror Along Diagonal (9x9 Only)
+F6 - Swap Digits Randomly (9x9 Only)
+F7 - Disguise Game Randomly (9x9 Only)
+F8/Shift+F8 - Set Game On/Off, Set New Game
+F9 - Next Arrow or Line Kind to Set
+F10/Shift+F10 - Swap Selected Row/Column Within its Band/Stack (9x9 Only)
+F11/Shift+F11 - Swap Selected Band/Stack with the Next (9x9 Only)
+Ctrl +/-, Ctrl+Mouse Wheel - Zoom In/Out
+Mouse Wheel/Shift+Mouse Wheel/Middle Mouse Drag - Scroll Large Boards
+Enter - Check Solution
+Space/Tab - Next Input Mode (Normal, Corner, Center, Color)
+Number - Enter Number or Mark for the Input Mode
+Shift+Number - Pencil Mark Corner (Number Row Only, Use Corner Mode on the Numpad)
+Control+Number - Pencil Mark Center
+Alt+Number - Color Cell
+A-G/Shift+A-G - Number/Corner Mark 10 to 16 in 12x12 and 16x16
+Ctrl+Alt+A-G - Center Mark 10 to 16 in 12x12 and 16x16
+Delete/Backspace - Clear Number/Marks for the Input Mode
+Alt+Delete/Backspace - Clear Colors
+Ctrl+Z - Undo
+Mouse/Arrow Keys - Select Cells
+Escape - Clear Selection
+Ctrl+C - Copy Game to Clipboard as Text
+Ctrl+S/O - Save/Open Game with its Rules and Progress, or as f-puzzles JSON
+Ctrl+L/Ctrl+Shift+L - Copy SudokuPad Link/Open Link or f-puzzles JSON from Clipboard
+Ctrl+E/Ctrl+Shift+E - Export Board as PNG/SVG Image
+Ctrl+Shift+C/V - Copy/Paste Jigsaw Regions as Text
+`
+
 func main() {
-	largeFont, _ := wui.NewFont(wui.FontDesc{
-		Name:   "Tahoma",
-		Height: tileSize - tileSize/10,
-	})
+	rand.Seed(time.Now().UnixNano())
 
-	mediumFont, _ := wui.NewFont(wui.FontDesc{
-		Name:   "Tahoma",
-		Height: mediumFontHeight,
-	})
+	var (
+		fonts        [fontKindCount]*wui.Font
+		helpFont     *wui.Font
+		fontTileSize int
+	)
+	// updateFonts creates the fonts for the current tile size. Resizing the
+	// window often keeps the tile size so we only do this if it changed.
+	updateFonts := func() {
+		if tileSize == fontTileSize {
+			return
+		}
+		fontTileSize = tileSize
 
-	smallFont, _ := wui.NewFont(wui.FontDesc{
-		Name:   "Tahoma",
-		Height: tileSize / 4,
-	})
+		for f := largeFont; f < fontKindCount; f++ {
+			fonts[f], _ = wui.NewFont(wui.FontDesc{
+				Name:   "Tahoma",
+				Height: fontHeight(f),
+			})
+		}
+
+		helpFont, _ = wui.NewFont(wui.FontDesc{
+			Name:   "Tahoma",
+			Height: helpFontHeight(),
+		})
+	}
+	updateFonts()
 
 	icon, _ := wui.NewIconFromExeResource(10)
 
 	window := wui.NewWindow()
 	window.SetTitle("Soduko")
 	window.SetIcon(icon)
-	window.SetInnerSize(boardSize, boardSize)
-	window.SetResizable(false)
-	window.SetHasMaxButton(false)
+	window.SetInnerSize(windowSize())
+
+	b := newBoard(gridShape.size)
+	mode := normalInput
+	gameRules := classicRules()
+	// In setter mode, digits are entered as givens and arrows and lines are
+	// drawn by dragging the right mouse button.
+	setterMode := false
+	tool := arrowTool
+	// newLine are the cells of the arrow or line being drawn. An arrow
+	// starts with its circle.
+	var newLine []int
 
-	var b board
+	var lastSelection [2]int
 
-	wantHighlight := func(n int) bool {
-		ok := false
-		for y := 0; y < 9; y++ {
-			for x := 0; x < 9; x++ {
-				if b[x][y].hot {
-					ok = true
-					if b[x][y].number != n {
-						return false
-					}
-				}
+	// inGrid returns false for the holes between the grids of a samurai,
+	// they cannot be selected.
+	inGrid := func(x, y int) bool {
+		return !gridShape.hole(x + gridShape.size*y)
+	}
+
+	// step goes from column x and row y in the given direction, over holes,
+	// wrapping around at the borders of the board.
+	step := func(x, y, dx, dy int) (int, int) {
+		for {
+			x = (x + dx + len(b)) % len(b)
+			y = (y + dy + len(b)) % len(b)
+			if inGrid(x, y) {
+				return x, y
 			}
 		}
-		return ok
 	}
 
 	board := wui.NewPaintBox()
 	window.Add(board)
 	board.SetBounds(0, 0, window.InnerWidth(), window.InnerHeight())
-	board.SetOnPaint(func(canvas *wui.Canvas) {
+	board.SetOnPaint(func(c *wui.Canvas) {
+		canvas := canvasPainter{canvas: c, fonts: fonts}
+		canvas.FillRect(0, 0, c.Width(), c.Height(), backColor)
 		if gameMode {
-			canvas.FillRect(0, 0, boardSize, boardSize, borderColor)
-			for row := 0; row < 9; row++ {
-				for col := 0; col < 9; col++ {
-					f := b[col][row]
-					x, y := tileTopLeft(col, row)
-
-					color := backColor
-					if f.hot {
-						color = hotColor
-					}
-					canvas.FillRect(x, y, tileSize, tileSize, color)
-
-					if f.number > 0 {
-						text := strconv.Itoa(f.number)
+			paintBoard(canvas, b, gameRules, newLine, tool == arrowTool)
+
+			// Draw the panel with the input modes and digits for mouse input.
+			canvas.FillRect(panelX(), boardY, panelWidth, boardSize, borderColor)
+			for _, button := range panelButtons() {
+				x, y, w, h := button.x, button.y, button.w, button.h
+				color := backColor
+				if button.kind == modeButton && inputMode(button.value) == mode {
+					color = hotColor
+				}
+				canvas.FillRect(x, y, w, h, color)
+
+				canvas.SetFont(smallFont)
+				switch button.kind {
+				case modeButton:
+					canvas.TextRectFormat(x, y, w, h, inputMode(button.value).String(), alignCenter, textColor)
+				case deleteButton:
+					canvas.TextRectFormat(x, y, w, h, "Delete", alignCenter, textColor)
+				case checkButton:
+					canvas.TextRectFormat(x, y, w, h, "Check", alignCenter, textColor)
+				case digitButton:
+					// Show the digit the way it will appear in the cells.
+					i := button.value - 1
+					text := digitName(button.value)
+					switch mode {
+					case normalInput:
 						canvas.SetFont(largeFont)
-						w, h := canvas.TextExtent(text)
-						color := textColor
-						if f.fixed {
-							color = fixedColor
-						}
-						if wantHighlight(f.number) {
-							canvas.FillRect(x, y, tileSize, tileSize, highlightBackColor)
-						}
-						canvas.TextOut(x+(tileSize-w)/2, y+(tileSize-h)/2, text, color)
-					} else {
-						// Draw pencil marks.
-						// Draw the corner marks.
-						canvas.SetFont(smallFont)
-						for i := 0; i < 9; i++ {
-							if f.corner[i] {
-								text := strconv.Itoa(i + 1)
-								w, h := canvas.TextExtent(text)
-								bx, by, bw, bh := cornerPencilMarkBounds(i)
-								canvas.TextOut(x+bx+(bw-w)/2, y+by+(bh-h)/2, text, textColor)
-							}
-						}
-						// Draw the center marks.
-						var centerText string
-						for i := 0; i < 9; i++ {
-							if f.center[i] {
-								centerText += strconv.Itoa(i + 1)
-							}
+						canvas.TextRectFormat(x, y, w, h, text, alignCenter, textColor)
+					case cornerInput:
+						tw, th := canvas.TextExtent(text)
+						bx, by, bw, bh := cornerPencilMarkBounds(i)
+						canvas.TextOut(x+bx+(bw-tw)/2, y+by+(bh-th)/2, text, textColor)
+					case centerInput:
+						canvas.TextRectFormat(x, y, w, h, text, alignCenter, textColor)
+					case colorInput:
+						if i < len(markColors) {
+							canvas.FillRect(x, y, w, h, markColors[i])
 						}
-						half := len(centerText) / 2
-						if half >= 3 {
-							centerText = centerText[:half] + "\n" + centerText[half:]
-						}
-						canvas.TextRectFormat(x, y, tileSize, tileSize, centerText, wui.FormatCenter, textColor)
+						canvas.TextRectFormat(x, y, w, h, text, alignCenter, textColor)
 					}
 				}
 			}
+
+			// List the digit combinations for the selected killer cage.
+			x, y, w, h := panelTextBounds()
+			canvas.FillRect(x, y, w, h, backColor)
+			col, row := lastSelection[0], lastSelection[1]
+			c, inCage := cage{}, false
+			if col != -1 && b[col][row].hot {
+				c, inCage = gameRules.cageAt(col + gridShape.size*row)
+			}
+			if inCage {
+				var placed []int
+				for _, i := range c.cells {
+					if n := b[i%len(b)][i/len(b)].number; n != 0 {
+						placed = append(placed, n)
+					}
+				}
+				text := "Cage: " + strconv.Itoa(c.sum) + " in " + strconv.Itoa(len(c.cells)) + "\n"
+				combinations := cageCombinations(c.sum, len(c.cells), placed)
+				if len(combinations) == 0 {
+					text += "No combinations"
+				}
+				for _, digits := range combinations {
+					for _, n := range digits {
+						text += strconv.Itoa(n)
+					}
+					text += " "
+				}
+				margin := tileSize / 10
+				canvas.SetFont(smallFont)
+				canvas.TextRectFormat(x+margin, y+margin, w-2*margin, h-2*margin, text, alignTopLeft, textColor)
+			} else if setterMode {
+				margin := tileSize / 10
+				canvas.SetFont(smallFont)
+				canvas.TextRectFormat(x+margin, y+margin, w-2*margin, h-2*margin,
+					"Setter Mode\nNumbers are givens. Drag the right mouse button to draw a "+tool.String()+
+						", F9 for the next kind. Right click to remove arrows and lines. F8 to finish.",
+					alignTopLeft, textColor)
+			}
 		} else {
-			canvas.FillRect(0, 0, boardSize, boardSize, backColor)
-			canvas.SetFont(mediumFont)
-			canvas.TextRectFormat(0, 0, boardSize, boardSize, `
-F1 - Help On/Off
-F2 - New Game
-Ctrl +/- - Zoom In/Out
-Enter - Check Solution
-Number - Enter Number
-Shift+Number - Pencil Mark Corner
-Control+Number - Pencil Mark Center
-Delete/Backspace - Clear Number/Pencil Marks
-Mouse/Arrow Keys - Select Cells
-Escape - Clear Selection
-Ctrl+C - Copy Game to Clipboard as Text
-`, wui.FormatCenter, textColor)
+			c.SetFont(helpFont)
+			w, h := windowSize()
+			c.TextRectFormat(boardX-clueMargin, boardY-clueMargin, w, h, helpText, wui.FormatCenter, wuiColor(textColor))
 		}
 	})
 	board.SetAnchors(wui.AnchorMinAndMax, wui.AnchorMinAndMax)
 
+	window.SetOnResize(func() {
+		layoutWindow(window.InnerSize())
+		updateFonts()
+		board.Paint()
+	})
+
+	var history undoHistory
+	undo := func() {
+		if gameMode {
+			history.undo(&b, &gameRules)
+			board.Paint()
+		}
+	}
+
 	putNumber := func(n int) func() {
 		return func() {
-			if !gameMode {
+			if !gameMode || n > gridShape.digits() {
 				return
 			}
-			for y := 0; y < 9; y++ {
-				for x := 0; x < 9; x++ {
+			defer history.change(&b, &gameRules)()
+			for y := range b {
+				for x := range b {
 					if b[x][y].hot && !b[x][y].fixed {
 						b[x][y].number = n
 					}
@@ -165,22 +264,23 @@ Ctrl+C - Copy Game to Clipboard as Text
 
 	putCenterPencilMark := func(n int) func() {
 		return func() {
-			if !gameMode {
+			if !gameMode || n > gridShape.digits() {
 				return
 			}
+			defer history.change(&b, &gameRules)()
 
 			var setMark bool
 
-			for y := 0; y < 9; y++ {
-				for x := 0; x < 9; x++ {
+			for y := range b {
+				for x := range b {
 					if b[x][y].hot && b[x][y].number == 0 && !b[x][y].center[n-1] {
 						setMark = true
 					}
 				}
 			}
 
-			for y := 0; y < 9; y++ {
-				for x := 0; x < 9; x++ {
+			for y := range b {
+				for x := range b {
 					if b[x][y].hot && b[x][y].number == 0 {
 						b[x][y].center[n-1] = setMark
 					}
@@ -193,22 +293,23 @@ Ctrl+C - Copy Game to Clipboard as Text
 
 	putCornerPencilMark := func(n int) func() {
 		return func() {
-			if !gameMode {
+			if !gameMode || n > gridShape.digits() {
 				return
 			}
+			defer history.change(&b, &gameRules)()
 
 			var setMark bool
 
-			for y := 0; y < 9; y++ {
-				for x := 0; x < 9; x++ {
+			for y := range b {
+				for x := range b {
 					if b[x][y].hot && b[x][y].number == 0 && !b[x][y].corner[n-1] {
 						setMark = true
 					}
 				}
 			}
 
-			for y := 0; y < 9; y++ {
-				for x := 0; x < 9; x++ {
+			for y := range b {
+				for x := range b {
 					if b[x][y].hot && b[x][y].number == 0 {
 						b[x][y].corner[n-1] = setMark
 					}
@@ -219,14 +320,44 @@ Ctrl+C - Copy Game to Clipboard as Text
 		}
 	}
 
+	putColorMark := func(n int) func() {
+		return func() {
+			if !gameMode || n > len(markColors) {
+				return
+			}
+			defer history.change(&b, &gameRules)()
+
+			var setMark bool
+
+			for y := range b {
+				for x := range b {
+					if b[x][y].hot && !b[x][y].colors[n-1] {
+						setMark = true
+					}
+				}
+			}
+
+			for y := range b {
+				for x := range b {
+					if b[x][y].hot {
+						b[x][y].colors[n-1] = setMark
+					}
+				}
+			}
+
+			board.Paint()
+		}
+	}
+
 	clearFields := func() {
 		if !gameMode {
 			return
 		}
+		defer history.change(&b, &gameRules)()
 
 		var hasNumber, hasCenter bool
-		for y := 0; y < 9; y++ {
-			for x := 0; x < 9; x++ {
+		for y := range b {
+			for x := range b {
 				if b[x][y].hot && !b[x][y].fixed {
 					hasNumber = hasNumber || b[x][y].number != 0
 					for i := range b[x][y].center {
@@ -238,8 +369,8 @@ Ctrl+C - Copy Game to Clipboard as Text
 
 		if hasNumber {
 			// Delete number.
-			for y := 0; y < 9; y++ {
-				for x := 0; x < 9; x++ {
+			for y := range b {
+				for x := range b {
 					if b[x][y].hot && !b[x][y].fixed {
 						b[x][y].number = 0
 					}
@@ -247,8 +378,8 @@ Ctrl+C - Copy Game to Clipboard as Text
 			}
 		} else if hasCenter {
 			// Delete center pencil mark.
-			for y := 0; y < 9; y++ {
-				for x := 0; x < 9; x++ {
+			for y := range b {
+				for x := range b {
 					if b[x][y].hot && !b[x][y].fixed {
 						for i := range b[x][y].center {
 							b[x][y].center[i] = false
@@ -258,8 +389,8 @@ Ctrl+C - Copy Game to Clipboard as Text
 			}
 		} else {
 			// Delete corner pencil mark.
-			for y := 0; y < 9; y++ {
-				for x := 0; x < 9; x++ {
+			for y := range b {
+				for x := range b {
 					if b[x][y].hot && !b[x][y].fixed {
 						for i := range b[x][y].corner {
 							b[x][y].corner[i] = false
@@ -276,9 +407,10 @@ Ctrl+C - Copy Game to Clipboard as Text
 		if !gameMode {
 			return
 		}
+		defer history.change(&b, &gameRules)()
 
-		for y := 0; y < 9; y++ {
-			for x := 0; x < 9; x++ {
+		for y := range b {
+			for x := range b {
 				if b[x][y].hot && !b[x][y].fixed && b[x][y].number == 0 {
 					for i := range b[x][y].corner {
 						b[x][y].corner[i] = false
@@ -293,9 +425,10 @@ Ctrl+C - Copy Game to Clipboard as Text
 		if !gameMode {
 			return
 		}
+		defer history.change(&b, &gameRules)()
 
-		for y := 0; y < 9; y++ {
-			for x := 0; x < 9; x++ {
+		for y := range b {
+			for x := range b {
 				if b[x][y].hot && !b[x][y].fixed && b[x][y].number == 0 {
 					for i := range b[x][y].center {
 						b[x][y].center[i] = false
@@ -306,27 +439,128 @@ Ctrl+C - Copy Game to Clipboard as Text
 		board.Paint()
 	}
 
-	var lastSelection [2]int
-	moveSelection := func(dx, dy int) {
+	clearColors := func() {
 		if !gameMode {
 			return
 		}
+		defer history.change(&b, &gameRules)()
 
-		s := lastSelection
-		if s[0] != -1 {
-			for y := 0; y < 9; y++ {
-				for x := 0; x < 9; x++ {
-					b[x][y].hot = false
+		for y := range b {
+			for x := range b {
+				if b[x][y].hot {
+					for i := range b[x][y].colors {
+						b[x][y].colors[i] = false
+					}
+				}
+			}
+		}
+		board.Paint()
+	}
+
+	// putGiven sets the selected cells as givens in setter mode, 0 clears
+	// them.
+	putGiven := func(n int) {
+		if !gameMode || n > gridShape.digits() {
+			return
+		}
+		defer history.change(&b, &gameRules)()
+		for y := range b {
+			for x := range b {
+				if b[x][y].hot {
+					b[x][y].number = n
+					b[x][y].fixed = n != 0
 				}
 			}
-			x := ((s[0] + dx) + 9) % 9
-			y := ((s[1] + dy) + 9) % 9
-			b[x][y].hot = true
-			lastSelection = [2]int{x, y}
 		}
 		board.Paint()
 	}
 
+	putDigit := func(n int) func() {
+		return func() {
+			switch mode {
+			case normalInput:
+				if setterMode {
+					putGiven(n)
+				} else {
+					putNumber(n)()
+				}
+			case cornerInput:
+				putCornerPencilMark(n)()
+			case centerInput:
+				putCenterPencilMark(n)()
+			case colorInput:
+				putColorMark(n)()
+			}
+		}
+	}
+
+	clearLayer := func() {
+		switch mode {
+		case normalInput:
+			if setterMode {
+				putGiven(0)
+			} else {
+				clearFields()
+			}
+		case cornerInput:
+			clearCorners()
+		case centerInput:
+			clearCenter()
+		case colorInput:
+			clearColors()
+		}
+	}
+
+	cycleInputMode := func(delta inputMode) func() {
+		return func() {
+			if !gameMode {
+				return
+			}
+			mode = (mode + delta + inputModeCount) % inputModeCount
+			board.Paint()
+		}
+	}
+
+	// showCell scrolls the board so the tile in the given column and row is
+	// inside the window.
+	showCell := func(col, row int) {
+		width, height := window.InnerSize()
+		x, y := tileTopLeft(col, row)
+		if x < 0 {
+			scrollX += x
+		} else if x+tileSize > width {
+			scrollX += x + tileSize - width
+		}
+		if y < 0 {
+			scrollY += y
+		} else if y+tileSize > height {
+			scrollY += y + tileSize - height
+		}
+		layoutWindow(width, height)
+	}
+
+	moveSelection := func(dx, dy int) func() {
+		return func() {
+			if !gameMode {
+				return
+			}
+
+			s := lastSelection
+			if s[0] != -1 {
+				for y := range b {
+					for x := range b {
+						b[x][y].hot = false
+					}
+				}
+				x, y := step(s[0], s[1], dx, dy)
+				b[x][y].hot = true
+				lastSelection = [2]int{x, y}
+				showCell(x, y)
+			}
+			board.Paint()
+		}
+	}
+
 	expandSelection := func(dx, dy int) func() {
 		return func() {
 			if !gameMode {
@@ -335,10 +569,10 @@ Ctrl+C - Copy Game to Clipboard as Text
 
 			s := lastSelection
 			if s[0] != -1 {
-				x := ((s[0] + dx) + 9) % 9
-				y := ((s[1] + dy) + 9) % 9
+				x, y := step(s[0], s[1], dx, dy)
 				b[x][y].hot = true
 				lastSelection = [2]int{x, y}
+				showCell(x, y)
 			}
 			board.Paint()
 		}
@@ -349,9 +583,9 @@ Ctrl+C - Copy Game to Clipboard as Text
 			return
 		}
 
-		for y := 0; y < 9; y++ {
-			for x := 0; x < 9; x++ {
-				b[x][y].hot = true
+		for y := range b {
+			for x := range b {
+				b[x][y].hot = inGrid(x, y)
 			}
 		}
 		board.Paint()
@@ -362,20 +596,74 @@ Ctrl+C - Copy Game to Clipboard as Text
 			return
 		}
 
-		for y := 0; y < 9; y++ {
-			for x := 0; x < 9; x++ {
+		for y := range b {
+			for x := range b {
 				b[x][y].hot = false
 			}
 		}
 		board.Paint()
 	}
 
-	solution := sudoku.Game{-1}
+	// startGame clears the board and puts the givens of a new game on it.
+	// The board takes the shape of the game.
+	startGame := func(r rules, start grid) {
+		gameRules = r
+		ring := r.hasHint(clueHint) || r.hasHint(littleKillerHint)
+		s := shapeOfSize(start.size())
+		if ring != clueRing || s != gridShape {
+			clueRing = ring
+			gridShape = s
+			// The new board starts out fitting the window.
+			zoomedTileSize, scrollX, scrollY = 0, 0, 0
+			layoutWindow(window.InnerSize())
+			updateFonts()
+		}
+		setterMode = false
+		lastSelection = [2]int{}
+		b = givensBoard(start)
+		history = nil
+		gameMode = true
+		board.Paint()
+	}
+
 	givenDigits := 30
+	variantIndex := 0
+	shapeIndex := 0
+	for i, s := range shapes {
+		if s == classicShape {
+			shapeIndex = i
+		}
+	}
+	antiKnightRule := false
+	antiKingRule := false
+	// extraRules are the constraints which the player can add to any variant.
+	extraRules := func(s shape) rules {
+		var extra rules
+		if antiKnightRule {
+			extra = append(extra, antiKnight(s.size))
+		}
+		if antiKingRule {
+			extra = append(extra, antiKing(s.size))
+		}
+		return extra
+	}
+	// played are the games of this session. A game that comes back
+	// transformed, e.g. disguised and shared as a link, is recognized.
+	played := gameHistory{}
+	// repeated remembers the game and returns true if it was played before.
+	// Only games whose rules allow all transforms are compared, for other
+	// rules equivalent givens can still be different games.
+	repeated := func(r rules, givens grid) bool {
+		if givens.size() != 9 || !r.symmetricUnderAll() {
+			return false
+		}
+		return !played.add(givens)
+	}
+
 	newGame := func() {
 		dlg := wui.NewWindow()
-		dlg.SetFont(mediumFont)
-		dlg.SetInnerSize(9*tileSize, 3*mediumFontHeight)
+		dlg.SetFont(fonts[mediumFont])
+		dlg.SetInnerSize(9*tileSize, 9*mediumFontHeight)
 		dlg.SetHasBorder(false)
 		dlg.SetResizable(false)
 		dlg.SetPosition(
@@ -383,23 +671,63 @@ Ctrl+C - Copy Game to Clipboard as Text
 			window.Y()+(window.Height()-dlg.Height())/2,
 		)
 
+		kindLabel := wui.NewLabel()
+		dlg.Add(kindLabel)
+		kindLabel.SetBounds(0, mediumFontHeight, 4*tileSize, mediumFontHeight)
+		kindLabel.SetAlignment(wui.AlignRight)
+		kindLabel.SetText("Play ")
+
+		kind := wui.NewComboBox()
+		dlg.Add(kind)
+		kind.SetBounds(4*tileSize, mediumFontHeight, 4*tileSize, mediumFontHeight)
+		for _, v := range variants {
+			kind.AddItem(v.name)
+		}
+		kind.SetSelectedIndex(variantIndex)
+
+		sizeLabel := wui.NewLabel()
+		dlg.Add(sizeLabel)
+		sizeLabel.SetBounds(0, 3*mediumFontHeight, 4*tileSize, mediumFontHeight)
+		sizeLabel.SetAlignment(wui.AlignRight)
+		sizeLabel.SetText("in ")
+
+		size := wui.NewComboBox()
+		dlg.Add(size)
+		size.SetBounds(4*tileSize, 3*mediumFontHeight, 2*tileSize, mediumFontHeight)
+		for _, s := range shapes {
+			size.AddItem(s.String())
+		}
+		size.SetSelectedIndex(shapeIndex)
+
 		left := wui.NewLabel()
 		dlg.Add(left)
-		left.SetBounds(0, mediumFontHeight, 4*tileSize, mediumFontHeight)
+		left.SetBounds(0, 5*mediumFontHeight, 4*tileSize, mediumFontHeight)
 		left.SetAlignment(wui.AlignRight)
 		left.SetText("Give me at least ")
 
 		digits := wui.NewIntUpDown()
 		dlg.Add(digits)
-		digits.SetBounds(4*tileSize, mediumFontHeight, 2*tileSize, mediumFontHeight+mediumFontHeight/8)
-		digits.SetMinMax(17, 80)
+		digits.SetBounds(4*tileSize, 5*mediumFontHeight, 2*tileSize, mediumFontHeight+mediumFontHeight/8)
+		digits.SetMinMax(0, 255)
 		digits.SetValue(givenDigits)
 
 		right := wui.NewLabel()
 		dlg.Add(right)
-		right.SetBounds(6*tileSize, mediumFontHeight, 3*tileSize, mediumFontHeight)
+		right.SetBounds(6*tileSize, 5*mediumFontHeight, 3*tileSize, mediumFontHeight)
 		right.SetText(" numbers.")
 
+		knight := wui.NewCheckBox()
+		dlg.Add(knight)
+		knight.SetBounds(2*tileSize, 7*mediumFontHeight, 3*tileSize, mediumFontHeight)
+		knight.SetText("Anti-Knight")
+		knight.SetChecked(antiKnightRule)
+
+		king := wui.NewCheckBox()
+		dlg.Add(king)
+		king.SetBounds(5*tileSize, 7*mediumFontHeight, 3*tileSize, mediumFontHeight)
+		king.SetText("Anti-King")
+		king.SetChecked(antiKingRule)
+
 		dlg.SetOnShow(func() {
 			digits.Focus()
 			digits.SelectAll()
@@ -408,6 +736,14 @@ Ctrl+C - Copy Game to Clipboard as Text
 		var wantNewGame bool
 		ok := func() {
 			givenDigits = digits.Value()
+			antiKnightRule = knight.Checked()
+			antiKingRule = king.Checked()
+			if kind.SelectedIndex() != -1 {
+				variantIndex = kind.SelectedIndex()
+			}
+			if size.SelectedIndex() != -1 {
+				shapeIndex = size.SelectedIndex()
+			}
 			dlg.Close()
 			wantNewGame = true
 		}
@@ -424,37 +760,32 @@ Ctrl+C - Copy Game to Clipboard as Text
 			return
 		}
 
-		lastSelection = [2]int{}
-		var start sudoku.Game
-		solution, start = generateNewGame(givenDigits)
-		for y := 0; y < 9; y++ {
-			for x := 0; x < 9; x++ {
-				b[x][y].number = start[x+9*y]
-				for i := range b[x][y].center {
-					b[x][y].center[i] = false
-				}
-				for i := range b[x][y].corner {
-					b[x][y].corner[i] = false
-				}
-				b[x][y].hot = false
-				b[x][y].fixed = b[x][y].number != 0
-			}
+		s := shapes[shapeIndex]
+		r, start, err := variants[variantIndex].newGame(s, extraRules(s), givenDigits)
+		for i := 0; i < 10 && err == nil && repeated(r, start); i++ {
+			r, start, err = variants[variantIndex].newGame(s, extraRules(s), givenDigits)
 		}
-		gameMode = true
-		board.Paint()
+		if err != nil {
+			wui.MessageBoxError("No Game", "Cannot create a game for these rules: "+err.Error()+".")
+			return
+		}
+		startGame(r, start)
 	}
 
 	checkGame := func() {
 		if !gameMode {
 			return
 		}
-		var have sudoku.Game
-		for y := 0; y < 9; y++ {
-			for x := 0; x < 9; x++ {
-				have[x+y*9] = b[x][y].number
-			}
+		have := b.game()
+		full := true
+		for i, n := range have {
+			full = full && (n != 0 || gridShape.hole(i))
+		}
+		conflicts := gameRules.conflicts(have)
+		for _, bad := range conflicts {
+			full = full && !bad
 		}
-		if have == solution {
+		if full {
 			wui.MessageBoxInfo("You Win!", "This is correct.")
 		} else {
 			wui.MessageBoxError("Not Yet!", "Your answer is wrong.")
@@ -466,53 +797,100 @@ Ctrl+C - Copy Game to Clipboard as Text
 		board.Paint()
 	}
 
-	zoom := func(delta int) {
-		tileSize += delta
-		if tileSize < 30 {
-			tileSize = 30
+	// toggleSetterMode switches between setting and solving. When the setter
+	// is done, they are told whether the game has a unique solution.
+	toggleSetterMode := func() {
+		if !gameMode {
+			return
 		}
+		setterMode = !setterMode
+		board.Paint()
+		if !setterMode {
+			switch gameRules.countSolutions(b.givens(), 2) {
+			case 0:
+				wui.MessageBoxWarning("Setter Mode", "This game has no solution.")
+			case 1:
+				wui.MessageBoxInfo("Setter Mode", "This game has a unique solution.")
+			default:
+				// Select the cells where two of the solutions differ, a
+				// clue in one of them rules out at least one solution.
+				for y := range b {
+					for x := range b {
+						b[x][y].hot = false
+					}
+				}
+				for _, i := range gameRules.ambiguousCells(b.givens()) {
+					b[i%len(b)][i/len(b)].hot = true
+				}
+				board.Paint()
+				wui.MessageBoxWarning("Setter Mode", "This game has more than one solution. The selected cells differ between two of them.")
+			}
+		}
+	}
 
-		thinBorderSize = 3
-		thickBorderSize = 3 * thinBorderSize
-		boardSize = 4*thickBorderSize + 6*thinBorderSize + 9*tileSize
-		mediumFontHeight = tileSize / 2
-		window.SetInnerSize(boardSize, boardSize)
-
-		largeFont, _ = wui.NewFont(wui.FontDesc{
-			Name:   "Tahoma",
-			Height: tileSize - tileSize/10,
-		})
+	// nextSetterTool changes what is drawn with the right mouse button.
+	nextSetterTool := func() {
+		if setterMode {
+			tool = (tool + 1) % setterToolCount
+			board.Paint()
+		}
+	}
 
-		mediumFont, _ = wui.NewFont(wui.FontDesc{
-			Name:   "Tahoma",
-			Height: mediumFontHeight,
-		})
+	// newSetterGame clears the board to set a game from scratch, in the shape
+	// of the current game.
+	newSetterGame := func() {
+		startGame(shapeRules(gridShape), newGrid(gridShape.size))
+		setterMode = true
+		board.Paint()
+	}
 
-		smallFont, _ = wui.NewFont(wui.FontDesc{
-			Name:   "Tahoma",
-			Height: tileSize / 4,
-		})
+	// zoom makes the tiles larger or smaller by the given factor. The window
+	// grows or shrinks with them as far as the screen lets it, beyond that
+	// the board becomes larger than the window and scrolls.
+	zoom := func(factor float64) {
+		size := int(float64(tileSize)*factor + 0.5)
+		if size < minTileSize {
+			size = minTileSize
+		}
+		zoomedTileSize = size
+		setTileSize(size)
+		window.SetInnerSize(windowSize())
+		layoutWindow(window.InnerSize())
+		updateFonts()
+		board.Paint()
+	}
+	zoomIn := func() { zoom(1.1) }
+	zoomOut := func() { zoom(1 / 1.1) }
+
+	// scroll moves the view over the board by the given number of pixels, as
+	// far as the board reaches beyond the window.
+	scroll := func(dx, dy int) {
+		scrollX += dx
+		scrollY += dy
+		layoutWindow(window.InnerSize())
+		board.Paint()
 	}
-	zoomIn := func() { zoom(1) }
-	zoomOut := func() { zoom(-1) }
 
 	copyBoard := func() {
 		var s string
-		for y := 0; y < 9; y++ {
-			for x := 0; x < 9; x++ {
+		last := gridShape.size - 1
+		for y := range b {
+			for x := range b {
 				n := b[x][y].number
-				if n == 0 {
+				if !inGrid(x, y) {
+					s += " "
+				} else if n == 0 {
 					s += "."
 				} else {
-					s += strconv.Itoa(n)
+					s += digitName(n)
 				}
-				if x < 8 && x%3 == 2 {
+				if x < last && x%gridShape.boxWidth == gridShape.boxWidth-1 {
 					s += " "
 				}
 			}
-			if y < 8 {
+			if y < last {
 				s += "\r\n"
-				if y%3 == 2 {
+				if y%gridShape.boxHeight == gridShape.boxHeight-1 {
 					s += "\r\n"
 				}
 			}
@@ -520,24 +898,302 @@ Ctrl+C - Copy Game to Clipboard as Text
 		copyTextToClipboard(s)
 	}
 
-	window.SetShortcut(putNumber(1), wui.Key1)
-	window.SetShortcut(putNumber(2), wui.Key2)
-	window.SetShortcut(putNumber(3), wui.Key3)
-	window.SetShortcut(putNumber(4), wui.Key4)
-	window.SetShortcut(putNumber(5), wui.Key5)
-	window.SetShortcut(putNumber(6), wui.Key6)
-	window.SetShortcut(putNumber(7), wui.Key7)
-	window.SetShortcut(putNumber(8), wui.Key8)
-	window.SetShortcut(putNumber(9), wui.Key9)
-	window.SetShortcut(putNumber(1), wui.KeyNum1)
-	window.SetShortcut(putNumber(2), wui.KeyNum2)
-	window.SetShortcut(putNumber(3), wui.KeyNum3)
-	window.SetShortcut(putNumber(4), wui.KeyNum4)
-	window.SetShortcut(putNumber(5), wui.KeyNum5)
-	window.SetShortcut(putNumber(6), wui.KeyNum6)
-	window.SetShortcut(putNumber(7), wui.KeyNum7)
-	window.SetShortcut(putNumber(8), wui.KeyNum8)
-	window.SetShortcut(putNumber(9), wui.KeyNum9)
+	saveGame := func() {
+		if !gameMode {
+			return
+		}
+		dlg := wui.NewFileSaveDialog()
+		dlg.SetTitle("Save Game")
+		dlg.AddFilter("Sudoku Game", ".sudoku")
+		dlg.AddFilter("f-puzzles Game", ".json")
+		dlg.SetAppendExt(true)
+		ok, path := dlg.Execute(window)
+		if !ok {
+			return
+		}
+		text, err := formatGame(b, gameRules)
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			text, err = formatFPuzzle(b.givens(), gameRules)
+		}
+		if err != nil {
+			wui.MessageBoxError("Error", "Cannot save the game: "+err.Error()+".")
+			return
+		}
+		if err := os.WriteFile(path, []byte(text), 0666); err != nil {
+			wui.MessageBoxError("Error", "Cannot save the game: "+err.Error()+".")
+		}
+	}
+
+	openGame := func() {
+		dlg := wui.NewFileOpenDialog()
+		dlg.SetTitle("Open Game")
+		dlg.AddFilter("Sudoku Game", ".sudoku")
+		dlg.AddFilter("f-puzzles Game", ".json")
+		ok, path := dlg.ExecuteSingleSelection(window)
+		if !ok {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			wui.MessageBoxError("Error", "Cannot open the game: "+err.Error()+".")
+			return
+		}
+		loaded, r, err := parseGameFile(path, string(data))
+		if err != nil {
+			wui.MessageBoxError("Error", "Cannot open the game: "+err.Error()+".")
+			return
+		}
+		if repeated(r, loaded.givens()) {
+			wui.MessageBoxInfo("Played Before", "You have played this game before, maybe transformed.")
+		}
+		// The loaded board has the player's progress as well.
+		startGame(r, loaded.givens())
+		b = loaded
+		board.Paint()
+	}
+
+	// copyLink copies a SudokuPad link to the game to the clipboard.
+	copyLink := func() {
+		if !gameMode {
+			return
+		}
+		link, err := formatFPuzzleLink(b.givens(), gameRules)
+		if err != nil {
+			wui.MessageBoxError("Error", "Cannot create a link: "+err.Error()+".")
+			return
+		}
+		copyTextToClipboard(link)
+	}
+
+	// openLink starts the game from a SudokuPad or f-puzzles link, or from
+	// f-puzzles JSON, in the clipboard.
+	openLink := func() {
+		givens, r, err := parseFPuzzleLink(getClipboardText())
+		if err != nil {
+			wui.MessageBoxError("Invalid Link", "Cannot open the game: "+err.Error()+".")
+			return
+		}
+		if repeated(r, givens) {
+			wui.MessageBoxInfo("Played Before", "You have played this game before, maybe transformed.")
+		}
+		startGame(r, givens)
+	}
+
+	// imageSize is the width and height of exported images in pixels.
+	imageSize := 1200
+
+	// exportImage saves the board as a PNG image. The user chooses its size,
+	// the zoom of the window does not matter.
+	exportImage := func() {
+		if !gameMode {
+			return
+		}
+		dlg := wui.NewWindow()
+		dlg.SetFont(fonts[mediumFont])
+		dlg.SetInnerSize(9*tileSize, 5*mediumFontHeight)
+		dlg.SetHasBorder(false)
+		dlg.SetResizable(false)
+		dlg.SetPosition(
+			window.X()+(window.Width()-dlg.Width())/2,
+			window.Y()+(window.Height()-dlg.Height())/2,
+		)
+
+		left := wui.NewLabel()
+		dlg.Add(left)
+		left.SetBounds(0, 2*mediumFontHeight, 4*tileSize, mediumFontHeight)
+		left.SetAlignment(wui.AlignRight)
+		left.SetText("Image size ")
+
+		size := wui.NewIntUpDown()
+		dlg.Add(size)
+		size.SetBounds(4*tileSize, 2*mediumFontHeight, 2*tileSize, mediumFontHeight+mediumFontHeight/8)
+		size.SetMinMax(400, 4000)
+		size.SetValue(imageSize)
+
+		right := wui.NewLabel()
+		dlg.Add(right)
+		right.SetBounds(6*tileSize, 2*mediumFontHeight, 3*tileSize, mediumFontHeight)
+		right.SetText(" pixels")
+
+		dlg.SetOnShow(func() {
+			size.Focus()
+			size.SelectAll()
+		})
+
+		var wantImage bool
+		dlg.SetShortcut(func() {
+			imageSize = size.Value()
+			wantImage = true
+			dlg.Close()
+		}, wui.KeyReturn)
+		dlg.SetShortcut(dlg.Close, wui.KeyEscape)
+		dlg.ShowModal()
+		dlg.Destroy()
+		if !wantImage {
+			return
+		}
+
+		save := wui.NewFileSaveDialog()
+		save.SetTitle("Export Image")
+		save.AddFilter("PNG Image", ".png")
+		save.SetAppendExt(true)
+		ok, path := save.Execute(window)
+		if !ok {
+			return
+		}
+
+		// Paint the board with the layout for the image, then go back to the
+		// window's.
+		layoutImage(imageSize)
+		img := newImagePainter(imageSize, imageSize)
+		img.FillRect(0, 0, imageSize, imageSize, backColor)
+		paintBoard(img, b, gameRules, nil, false)
+		layoutWindow(window.InnerSize())
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img.img); err != nil {
+			wui.MessageBoxError("Error", "Cannot create the image: "+err.Error()+".")
+			return
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0666); err != nil {
+			wui.MessageBoxError("Error", "Cannot save the image: "+err.Error()+".")
+		}
+	}
+
+	// exportSVG saves the board as an SVG image. It has the size of exported
+	// PNG images but can be scaled to any size.
+	exportSVG := func() {
+		if !gameMode {
+			return
+		}
+		dlg := wui.NewFileSaveDialog()
+		dlg.SetTitle("Export SVG Image")
+		dlg.AddFilter("SVG Image", ".svg")
+		dlg.SetAppendExt(true)
+		ok, path := dlg.Execute(window)
+		if !ok {
+			return
+		}
+
+		layoutImage(imageSize)
+		svg := newSVGPainter(imageSize, imageSize)
+		svg.FillRect(0, 0, imageSize, imageSize, backColor)
+		paintBoard(svg, b, gameRules, nil, false)
+		layoutWindow(window.InnerSize())
+
+		if err := os.WriteFile(path, svg.svg(), 0666); err != nil {
+			wui.MessageBoxError("Error", "Cannot save the image: "+err.Error()+".")
+		}
+	}
+
+	copyRegions := func() {
+		copyTextToClipboard(formatRegions(gameRules.regions()))
+	}
+
+	// pasteRegions starts a jigsaw game with the regions from the clipboard.
+	pasteRegions := func() {
+		regions, err := parseRegions(getClipboardText())
+		if err != nil {
+			wui.MessageBoxError("Invalid Regions", "The clipboard does not contain regions: "+err.Error()+".")
+			return
+		}
+		r, start, err := jigsawGame(regions, extraRules(classicShape), givenDigits, 50)
+		if err != nil {
+			wui.MessageBoxError("Invalid Regions", "Cannot create a game: "+err.Error()+".")
+			return
+		}
+		startGame(r, start)
+	}
+
+	transformGame := func(t func() transform) func() {
+		return func() {
+			if !gameMode {
+				return
+			}
+
+			if gridShape != classicShape {
+				wui.MessageBoxInfo("Not Possible", "Only 9x9 games can be transformed.")
+				return
+			}
+			t := t()
+			if !gameRules.symmetricUnder(t) {
+				wui.MessageBoxInfo("Not Possible", "The rules of this variant do not allow this transformation.")
+				return
+			}
+			defer history.change(&b, &gameRules)()
+			b = t.applyBoard(b)
+			col, row := t.target(lastSelection[0], lastSelection[1])
+			lastSelection = [2]int{col, row}
+			board.Paint()
+		}
+	}
+	rotate := transformGame(rotateTransform)
+	mirrorHorizontally := transformGame(func() transform { return mirrorTransform(true) })
+	mirrorVertically := transformGame(func() transform { return mirrorTransform(false) })
+	transpose := transformGame(transposeTransform)
+	relabel := transformGame(relabelTransform)
+	disguise := transformGame(func() transform { return disguiseTransform(gameRules) })
+	// The swaps move the lines of the last selected cell. The selection moves
+	// with them so pressing the key again keeps cycling the same line.
+	swapRow := transformGame(func() transform {
+		row := lastSelection[1]
+		return swapRowsTransform(row, row/3*3+(row+1)%3)
+	})
+	swapCol := transformGame(func() transform {
+		col := lastSelection[0]
+		return swapColsTransform(col, col/3*3+(col+1)%3)
+	})
+	swapBand := transformGame(func() transform {
+		band := lastSelection[1] / 3
+		return swapBandsTransform(band, (band+1)%3)
+	})
+	swapStack := transformGame(func() transform {
+		stack := lastSelection[0] / 3
+		return swapStacksTransform(stack, (stack+1)%3)
+	})
+
+	window.SetShortcut(putDigit(1), wui.Key1)
+	window.SetShortcut(putDigit(2), wui.Key2)
+	window.SetShortcut(putDigit(3), wui.Key3)
+	window.SetShortcut(putDigit(4), wui.Key4)
+	window.SetShortcut(putDigit(5), wui.Key5)
+	window.SetShortcut(putDigit(6), wui.Key6)
+	window.SetShortcut(putDigit(7), wui.Key7)
+	window.SetShortcut(putDigit(8), wui.Key8)
+	window.SetShortcut(putDigit(9), wui.Key9)
+	window.SetShortcut(putDigit(1), wui.KeyNum1)
+	window.SetShortcut(putDigit(2), wui.KeyNum2)
+	window.SetShortcut(putDigit(3), wui.KeyNum3)
+	window.SetShortcut(putDigit(4), wui.KeyNum4)
+	window.SetShortcut(putDigit(5), wui.KeyNum5)
+	window.SetShortcut(putDigit(6), wui.KeyNum6)
+	window.SetShortcut(putDigit(7), wui.KeyNum7)
+	window.SetShortcut(putDigit(8), wui.KeyNum8)
+	window.SetShortcut(putDigit(9), wui.KeyNum9)
+	// Digits above 9 are letters, see digitName.
+	window.SetShortcut(putDigit(10), wui.KeyA)
+	window.SetShortcut(putDigit(11), wui.KeyB)
+	window.SetShortcut(putDigit(12), wui.KeyC)
+	window.SetShortcut(putDigit(13), wui.KeyD)
+	window.SetShortcut(putDigit(14), wui.KeyE)
+	window.SetShortcut(putDigit(15), wui.KeyF)
+	window.SetShortcut(putDigit(16), wui.KeyG)
+	window.SetShortcut(putCornerPencilMark(10), wui.KeyShift, wui.KeyA)
+	window.SetShortcut(putCornerPencilMark(11), wui.KeyShift, wui.KeyB)
+	window.SetShortcut(putCornerPencilMark(12), wui.KeyShift, wui.KeyC)
+	window.SetShortcut(putCornerPencilMark(13), wui.KeyShift, wui.KeyD)
+	window.SetShortcut(putCornerPencilMark(14), wui.KeyShift, wui.KeyE)
+	window.SetShortcut(putCornerPencilMark(15), wui.KeyShift, wui.KeyF)
+	window.SetShortcut(putCornerPencilMark(16), wui.KeyShift, wui.KeyG)
+	// Ctrl+C and Ctrl+E are taken by copying and exporting, Alt is added to
+	// keep the center marks together.
+	window.SetShortcut(putCenterPencilMark(10), wui.KeyControl, wui.KeyAlt, wui.KeyA)
+	window.SetShortcut(putCenterPencilMark(11), wui.KeyControl, wui.KeyAlt, wui.KeyB)
+	window.SetShortcut(putCenterPencilMark(12), wui.KeyControl, wui.KeyAlt, wui.KeyC)
+	window.SetShortcut(putCenterPencilMark(13), wui.KeyControl, wui.KeyAlt, wui.KeyD)
+	window.SetShortcut(putCenterPencilMark(14), wui.KeyControl, wui.KeyAlt, wui.KeyE)
+	window.SetShortcut(putCenterPencilMark(15), wui.KeyControl, wui.KeyAlt, wui.KeyF)
+	window.SetShortcut(putCenterPencilMark(16), wui.KeyControl, wui.KeyAlt, wui.KeyG)
 	window.SetShortcut(putCenterPencilMark(1), wui.KeyControl, wui.Key1)
 	window.SetShortcut(putCenterPencilMark(2), wui.KeyControl, wui.Key2)
 	window.SetShortcut(putCenterPencilMark(3), wui.KeyControl, wui.Key3)
@@ -565,14 +1221,38 @@ Ctrl+C - Copy Game to Clipboard as Text
 	window.SetShortcut(putCornerPencilMark(7), wui.KeyShift, wui.Key7)
 	window.SetShortcut(putCornerPencilMark(8), wui.KeyShift, wui.Key8)
 	window.SetShortcut(putCornerPencilMark(9), wui.KeyShift, wui.Key9)
-	window.SetShortcut(clearFields, wui.KeyBack)
-	window.SetShortcut(clearFields, wui.KeyDelete)
-	window.SetShortcut(clearFields, wui.Key0)
-	window.SetShortcut(clearFields, wui.KeyNum0)
+	window.SetShortcut(putColorMark(1), wui.KeyAlt, wui.Key1)
+	window.SetShortcut(putColorMark(2), wui.KeyAlt, wui.Key2)
+	window.SetShortcut(putColorMark(3), wui.KeyAlt, wui.Key3)
+	window.SetShortcut(putColorMark(4), wui.KeyAlt, wui.Key4)
+	window.SetShortcut(putColorMark(5), wui.KeyAlt, wui.Key5)
+	window.SetShortcut(putColorMark(6), wui.KeyAlt, wui.Key6)
+	window.SetShortcut(putColorMark(7), wui.KeyAlt, wui.Key7)
+	window.SetShortcut(putColorMark(8), wui.KeyAlt, wui.Key8)
+	window.SetShortcut(putColorMark(9), wui.KeyAlt, wui.Key9)
+	window.SetShortcut(putColorMark(1), wui.KeyAlt, wui.KeyNum1)
+	window.SetShortcut(putColorMark(2), wui.KeyAlt, wui.KeyNum2)
+	window.SetShortcut(putColorMark(3), wui.KeyAlt, wui.KeyNum3)
+	window.SetShortcut(putColorMark(4), wui.KeyAlt, wui.KeyNum4)
+	window.SetShortcut(putColorMark(5), wui.KeyAlt, wui.KeyNum5)
+	window.SetShortcut(putColorMark(6), wui.KeyAlt, wui.KeyNum6)
+	window.SetShortcut(putColorMark(7), wui.KeyAlt, wui.KeyNum7)
+	window.SetShortcut(putColorMark(8), wui.KeyAlt, wui.KeyNum8)
+	window.SetShortcut(putColorMark(9), wui.KeyAlt, wui.KeyNum9)
+	window.SetShortcut(clearLayer, wui.KeyBack)
+	window.SetShortcut(clearLayer, wui.KeyDelete)
+	window.SetShortcut(clearLayer, wui.Key0)
+	window.SetShortcut(clearLayer, wui.KeyNum0)
 	window.SetShortcut(clearCorners, wui.KeyBack, wui.KeyShift)
 	window.SetShortcut(clearCorners, wui.KeyDelete, wui.KeyShift)
 	window.SetShortcut(clearCenter, wui.KeyBack, wui.KeyControl)
 	window.SetShortcut(clearCenter, wui.KeyDelete, wui.KeyControl)
+	window.SetShortcut(clearColors, wui.KeyBack, wui.KeyAlt)
+	window.SetShortcut(clearColors, wui.KeyDelete, wui.KeyAlt)
+	window.SetShortcut(moveSelection(1, 0), wui.KeyRight)
+	window.SetShortcut(moveSelection(-1, 0), wui.KeyLeft)
+	window.SetShortcut(moveSelection(0, 1), wui.KeyDown)
+	window.SetShortcut(moveSelection(0, -1), wui.KeyUp)
 	window.SetShortcut(expandSelection(1, 0), wui.KeyRight, wui.KeyShift)
 	window.SetShortcut(expandSelection(-1, 0), wui.KeyLeft, wui.KeyShift)
 	window.SetShortcut(expandSelection(0, 1), wui.KeyDown, wui.KeyShift)
@@ -583,6 +1263,10 @@ Ctrl+C - Copy Game to Clipboard as Text
 	window.SetShortcut(expandSelection(0, -1), wui.KeyUp, wui.KeyControl)
 	window.SetShortcut(selectAll, wui.KeyA, wui.KeyControl)
 	window.SetShortcut(unselectAll, wui.KeyEscape)
+	window.SetShortcut(cycleInputMode(1), wui.KeySpace)
+	window.SetShortcut(cycleInputMode(1), wui.KeyTab)
+	window.SetShortcut(cycleInputMode(-1), wui.KeyShift, wui.KeySpace)
+	window.SetShortcut(cycleInputMode(-1), wui.KeyShift, wui.KeyTab)
 	window.SetShortcut(newGame, wui.KeyF2)
 	window.SetShortcut(toggleHelp, wui.KeyF1)
 	window.SetShortcut(checkGame, wui.KeyReturn)
@@ -590,25 +1274,88 @@ Ctrl+C - Copy Game to Clipboard as Text
 	window.SetShortcut(zoomIn, wui.KeyControl, wui.KeyOEMPlus)
 	window.SetShortcut(zoomOut, wui.KeyControl, wui.KeySubtract)
 	window.SetShortcut(zoomOut, wui.KeyControl, wui.KeyOEMMinus)
+	window.SetShortcut(undo, wui.KeyControl, wui.KeyZ)
 	window.SetShortcut(copyBoard, wui.KeyControl, wui.KeyC)
+	window.SetShortcut(saveGame, wui.KeyControl, wui.KeyS)
+	window.SetShortcut(openGame, wui.KeyControl, wui.KeyO)
+	window.SetShortcut(copyLink, wui.KeyControl, wui.KeyL)
+	window.SetShortcut(openLink, wui.KeyControl, wui.KeyShift, wui.KeyL)
+	window.SetShortcut(exportImage, wui.KeyControl, wui.KeyE)
+	window.SetShortcut(exportSVG, wui.KeyControl, wui.KeyShift, wui.KeyE)
+	window.SetShortcut(copyRegions, wui.KeyControl, wui.KeyShift, wui.KeyC)
+	window.SetShortcut(pasteRegions, wui.KeyControl, wui.KeyShift, wui.KeyV)
+	window.SetShortcut(rotate, wui.KeyF3)
+	window.SetShortcut(mirrorHorizontally, wui.KeyF4)
+	window.SetShortcut(mirrorVertically, wui.KeyShift, wui.KeyF4)
+	window.SetShortcut(transpose, wui.KeyF5)
+	window.SetShortcut(relabel, wui.KeyF6)
+	window.SetShortcut(disguise, wui.KeyF7)
+	window.SetShortcut(toggleSetterMode, wui.KeyF8)
+	window.SetShortcut(newSetterGame, wui.KeyShift, wui.KeyF8)
+	window.SetShortcut(nextSetterTool, wui.KeyF9)
+	window.SetShortcut(swapRow, wui.KeyF10)
+	window.SetShortcut(swapCol, wui.KeyShift, wui.KeyF10)
+	window.SetShortcut(swapBand, wui.KeyF11)
+	window.SetShortcut(swapStack, wui.KeyShift, wui.KeyF11)
 
 	var (
 		selecting    bool
 		setSelection bool
+		// dragging is true while the middle mouse button pans the view,
+		// dragX and dragY are where the mouse was last.
+		dragging     bool
+		dragX, dragY int
 	)
 	window.SetOnMouseDown(func(button wui.MouseButton, x, y int) {
-		if button == wui.MouseButtonLeft {
+		if button == wui.MouseButtonMiddle {
+			dragging, dragX, dragY = true, x, y
+			return
+		}
+		if !(boardY <= y && y < boardY+boardSize) {
+			return
+		}
+		inBoard := boardX <= x && x < boardX+boardSize
+		inPanel := panelX() <= x && x < panelX()+panelWidth
+		if !inBoard && !inPanel {
+			return
+		}
+		if button == wui.MouseButtonLeft && inPanel {
+			if !gameMode {
+				return
+			}
+			for _, button := range panelButtons() {
+				if button.x <= x && x < button.x+button.w && button.y <= y && y < button.y+button.h {
+					switch button.kind {
+					case modeButton:
+						mode = inputMode(button.value)
+						board.Paint()
+					case digitButton:
+						putDigit(button.value)()
+					case deleteButton:
+						clearLayer()
+					case checkButton:
+						checkGame()
+					}
+				}
+			}
+		} else if button == wui.MouseButtonRight && inBoard && setterMode && gameMode && gridShape == classicShape {
+			col, row := screenToBoard(x, y)
+			newLine = []int{col + gridShape.size*row}
+		} else if button == wui.MouseButtonLeft {
 			shift := w32.GetKeyState(w32.VK_SHIFT)&0x80 != 0
 			control := w32.GetKeyState(w32.VK_CONTROL)&0x80 != 0
 			toggle := shift || control
 			col, row := screenToBoard(x, y)
+			if !inGrid(col, row) {
+				return
+			}
 			lastSelection = [2]int{col, row}
 			if toggle {
 				b[col][row].hot = !b[col][row].hot
 				setSelection = b[col][row].hot
 			} else {
-				for y := 0; y < 9; y++ {
-					for x := 0; x < 9; x++ {
+				for y := range b {
+					for x := range b {
 						b[x][y].hot = false
 					}
 				}
@@ -623,10 +1370,51 @@ Ctrl+C - Copy Game to Clipboard as Text
 		if button == wui.MouseButtonLeft {
 			selecting = false
 		}
+		if button == wui.MouseButtonMiddle {
+			dragging = false
+		}
+		if button == wui.MouseButtonRight && len(newLine) > 0 {
+			// A click without moving removes arrows and lines, dragging adds
+			// one.
+			done := history.change(&b, &gameRules)
+			if len(newLine) == 1 {
+				gameRules = gameRules.withoutArrowsAt(newLine[0]).withoutLinesAt(newLine[0])
+			} else if tool == arrowTool {
+				gameRules = gameRules.withArrow(arrow{
+					circle: newLine[0],
+					cells:  newLine[1:],
+				})
+			} else {
+				gameRules = gameRules.withLine(line{kind: tool.lineKind(), cells: newLine})
+			}
+			done()
+			newLine = nil
+			board.Paint()
+		}
 	})
 	window.SetOnMouseMove(func(x, y int) {
+		if dragging {
+			scroll(dragX-x, dragY-y)
+			dragX, dragY = x, y
+		}
+		if len(newLine) > 0 {
+			col, row := screenToBoard(x, y)
+			i := col + gridShape.size*row
+			last := newLine[len(newLine)-1]
+			if len(newLine) >= 2 && i == newLine[len(newLine)-2] {
+				// Going back takes back the last cell.
+				newLine = newLine[:len(newLine)-1]
+				board.Paint()
+			} else if indexOf(newLine, i) == -1 && indexOf(kingNeighbors(last), i) != -1 {
+				newLine = append(newLine, i)
+				board.Paint()
+			}
+		}
 		if selecting {
 			col, row := screenToBoard(x, y)
+			if !inGrid(col, row) {
+				return
+			}
 			lastSelection = [2]int{col, row}
 			if b[col][row].hot != setSelection {
 				b[col][row].hot = setSelection
@@ -634,311 +1422,292 @@ Ctrl+C - Copy Game to Clipboard as Text
 			}
 		}
 	})
-
-	// Handle Shift+Numpad keys.
-	window.SetOnMessage(func(window uintptr, msg uint32, w, l uintptr) (handled bool, result uintptr) {
-		if msg == w32.WM_KEYDOWN {
-			extended := l&(1<<24) != 0
-			if extended {
-				// This might have been a regular arrow key.
-				switch w {
-				case w32.VK_LEFT:
-					moveSelection(-1, 0)
-				case w32.VK_RIGHT:
-					moveSelection(1, 0)
-				case w32.VK_DOWN:
-					moveSelection(0, 1)
-				case w32.VK_UP:
-					moveSelection(0, -1)
-				}
-			} else {
-				n := 0
-				switch w {
-				case w32.VK_LEFT:
-					n = 4
-				case w32.VK_RIGHT:
-					n = 6
-				case w32.VK_UP:
-					n = 8
-				case w32.VK_DOWN:
-					n = 2
-				case w32.VK_HOME:
-					n = 7
-				case w32.VK_END:
-					n = 1
-				case w32.VK_PRIOR:
-					n = 9
-				case w32.VK_NEXT:
-					n = 3
-				case w32.VK_CLEAR:
-					n = 5
-				}
-				if n != 0 {
-					handled = true
-					putCornerPencilMark(n)()
-					board.Paint()
-				}
-			}
+	// The wheel scrolls up and down, with Shift left and right and with
+	// Control it zooms.
+	window.SetOnMouseWheel(func(x, y int, delta float64) {
+		if w32.GetKeyState(w32.VK_CONTROL)&0x80 != 0 {
+			zoom(math.Pow(1.1, delta))
+			return
+		}
+		pixels := int(-delta * float64(2*tileSize))
+		if w32.GetKeyState(w32.VK_SHIFT)&0x80 != 0 {
+			scroll(pixels, 0)
+		} else {
+			scroll(0, pixels)
 		}
-		return
 	})
 
 	window.Show()
 }
 
-func tileTopLeft(col, row int) (x, y int) {
-	x = (1+col/3)*(thickBorderSize-thinBorderSize) + col*(thinBorderSize+tileSize)
-	y = (1+row/3)*(thickBorderSize-thinBorderSize) + row*(thinBorderSize+tileSize)
-	return
+// helpFontHeight returns the medium font height, or less if the help text
+// would not fit onto the board otherwise.
+func helpFontHeight() int {
+	lines := strings.Count(helpText, "\n") + 1
+	if boardSize/lines < mediumFontHeight {
+		return boardSize / lines
+	}
+	return mediumFontHeight
 }
 
-func cornerPencilMarkBounds(i int) (x, y, w, h int) {
-	switch i {
-	case 0, 1, 2, 3, 4, 5, 6, 8:
-		w = tileSize / 4
-	case 7:
-		w = tileSize / 3
-	}
+// setterTool is what the right mouse button draws in setter mode.
+type setterTool int
 
-	switch i {
-	case 0, 1, 2, 3:
-		x = i * w
-	case 4, 6:
-		x = 0
-	case 5, 8:
-		x = 3 * w
-	case 7:
-		x = w
-	}
+const (
+	arrowTool setterTool = iota
+	whisperTool
+	renbanTool
+	palindromeTool
+	setterToolCount
+)
 
-	h = tileSize / 3
-	switch i {
-	case 0, 1, 2, 3:
-		y = 0
-	case 4, 5:
-		y = h
-	case 6, 7, 8:
-		y = 2 * h
+func (t setterTool) String() string {
+	if t == arrowTool {
+		return "arrow"
 	}
+	return t.lineKind().String() + " line"
+}
 
-	return
+// lineKind is the kind of line that the tool draws, it is only valid for
+// the line tools.
+func (t setterTool) lineKind() lineKind {
+	return whisperLine + lineKind(t-whisperTool)
 }
 
-func screenToBoard(x, y int) (col, row int) {
-	{
-		best := 9999999
-		for c := 0; c < 9; c++ {
-			cx, _ := tileTopLeft(c, 0)
-			cx += tileSize / 2
-			if abs(x-cx) < best {
-				col = c
-				best = abs(x - cx)
-			}
-		}
-	}
+// inputMode decides what the number keys and the digit buttons in the panel
+// do.
+type inputMode int
 
-	{
-		best := 9999999
-		for r := 0; r < 9; r++ {
-			_, cy := tileTopLeft(0, r)
-			cy += tileSize / 2
-			if abs(y-cy) < best {
-				row = r
-				best = abs(y - cy)
-			}
-		}
+const (
+	normalInput inputMode = iota
+	cornerInput
+	centerInput
+	colorInput
+	inputModeCount
+)
+
+func (m inputMode) String() string {
+	switch m {
+	case normalInput:
+		return "Normal"
+	case cornerInput:
+		return "Corner"
+	case centerInput:
+		return "Center"
+	case colorInput:
+		return "Color"
 	}
+	return "inputMode(" + strconv.Itoa(int(m)) + ")"
+}
 
-	return
+// panelButton is a clickable area in the panel to the right of the board.
+type panelButton struct {
+	x, y, w, h int
+	kind       panelButtonKind
+	// value is the inputMode for a modeButton and the digit for a
+	// digitButton.
+	value int
 }
 
-func abs(x int) int {
-	if x < 0 {
-		return -x
+type panelButtonKind int
+
+const (
+	modeButton panelButtonKind = iota
+	digitButton
+	deleteButton
+	checkButton
+)
+
+// panelButtons lays out the panel: the input modes on top, the digits below
+// them, arranged like a box of the board, and then the delete and check
+// buttons.
+func panelButtons() []panelButton {
+	var buttons []panelButton
+	x, y := panelX(), boardY+thickBorderSize
+	w := (3*panelTileSize + thinBorderSize) / 2
+	h := panelTileSize / 2
+
+	for m := normalInput; m < inputModeCount; m++ {
+		buttons = append(buttons, panelButton{
+			x:     x + int(m%2)*(w+thinBorderSize),
+			y:     y + int(m/2)*(h+thinBorderSize),
+			w:     w,
+			h:     h,
+			kind:  modeButton,
+			value: int(m),
+		})
+	}
+	y += 2*h + thinBorderSize + thickBorderSize
+
+	// The digits take the room of 3x3 buttons in a 9x9 game, their size
+	// depends on how many go into a row.
+	cols := gridShape.boxWidth
+	size := (3*panelTileSize + 2*thinBorderSize - (cols-1)*thinBorderSize) / cols
+	for i := 0; i < gridShape.digits(); i++ {
+		buttons = append(buttons, panelButton{
+			x:     x + (i%cols)*(size+thinBorderSize),
+			y:     y + (i/cols)*(size+thinBorderSize),
+			w:     size,
+			h:     size,
+			kind:  digitButton,
+			value: i + 1,
+		})
 	}
-	return x
+	y += 3*panelTileSize + 2*thinBorderSize + thickBorderSize
+
+	buttons = append(buttons,
+		panelButton{x: x, y: y, w: w, h: h, kind: deleteButton},
+		panelButton{x: x + w + thinBorderSize, y: y, w: w, h: h, kind: checkButton},
+	)
+	return buttons
 }
 
-type board [9][9]field
+// panelTextBounds is the area below the panel buttons. It shows information
+// about the selection, like the possible digits of a killer cage.
+func panelTextBounds() (x, y, w, h int) {
+	buttons := panelButtons()
+	last := buttons[len(buttons)-1]
+	x = panelX()
+	y = last.y + last.h + thickBorderSize
+	w = 3*panelTileSize + 2*thinBorderSize
+	h = boardY + boardSize - thickBorderSize - y
+	return
+}
 
-type field struct {
-	number int
-	corner [9]bool
-	center [9]bool
-	hot    bool
-	fixed  bool
+// windowSize is the inner window size that fits the board, its clue ring and
+// the panel.
+func windowSize() (width, height int) {
+	return boardSize + 2*clueMargin + panelWidth, boardSize + 2*clueMargin
 }
 
-func generateNewGame(givenDigits int) (solution, start sudoku.Game) {
-	rand.Seed(time.Now().UnixNano())
+// panelX is the left of the panel, which is right of the board and its clue
+// ring.
+func panelX() int {
+	return boardX + boardSize + clueMargin
+}
 
-	// Find a solvable game, our algorithm for this has a 10% chance of
-	// generating one.
+// layoutWindow chooses the largest tile size for which the board and the
+// panel fit into a window of the given inner size. They are centered in the
+// window so their aspect ratio is always the same. If they are zoomed in
+// further, see zoomedTileSize, or the window is too small even for the
+// smallest tiles, they are larger than the window and scrolled by scrollX and
+// scrollY.
+func layoutWindow(width, height int) {
+	size := height / gridShape.size
 	for {
-		var err error
-		solution, err = tryGeneratingGame()
-		if err == nil {
+		setTileSize(size)
+		w, h := windowSize()
+		if size <= minTileSize || w <= width && h <= height {
 			break
 		}
+		size--
 	}
-
-	// Randomize this game some more.
-	swapDigits := [9]int{1, 2, 3, 4, 5, 6, 7, 8, 9}
-	shuffle(swapDigits[:])
-	for i := range solution {
-		solution[i] = swapDigits[solution[i]-1]
+	if zoomedTileSize > tileSize {
+		setTileSize(zoomedTileSize)
+	} else {
+		// The window is large enough for the zoomed tiles now.
+		zoomedTileSize = 0
 	}
-
-	for i := 0; i < 1000; i++ {
-		a := rand.Intn(3) * 3
-		b := rand.Intn(3) + a
-		if rand.Intn(2) == 0 {
-			swapLines(&solution, a, b)
-		} else {
-			swapCols(&solution, a, b)
-		}
+	w, h := windowSize()
+	scrollX = clampScroll(scrollX, w-width)
+	scrollY = clampScroll(scrollY, h-height)
+	boardX = (width - w) / 2
+	boardY = (height - h) / 2
+	if boardX < 0 {
+		boardX = 0
 	}
-
-	// Remove digits while keeping the game uniquly solvable.
-	start = solution
-	have := 81
-	want := givenDigits
-
-	rest := make([]int, 81)
-	for i := range rest {
-		rest[i] = i
+	if boardY < 0 {
+		boardY = 0
 	}
+	boardX += clueMargin - scrollX
+	boardY += clueMargin - scrollY
+}
 
-	for len(rest) > 0 && have != want {
-		n := rand.Intn(len(rest))
-		i := rest[n]
-		was := start[i]
-		start[i] = 0
-		if sudoku.HasUniqueSolution(start) {
-			have--
-		} else {
-			start[i] = was
-		}
-		rest[0], rest[n] = rest[n], rest[0]
-		rest = rest[1:]
+// clampScroll returns the scroll offset limited to the part of the board
+// which is outside the window, max pixels.
+func clampScroll(scroll, max int) int {
+	if scroll > max {
+		scroll = max
+	}
+	if scroll < 0 {
+		scroll = 0
 	}
+	return scroll
+}
 
-	return
+// canvasPainter paints on the window's canvas with the window's fonts.
+type canvasPainter struct {
+	canvas *wui.Canvas
+	fonts  [fontKindCount]*wui.Font
 }
 
-func tryGeneratingGame() (sudoku.Game, error) {
-	// First row is always fixed as 1..9. We later alter the digits randomly.
-	game := sudoku.Game{1, 2, 3, 4, 5, 6, 7, 8, 9}
-
-	// For box 1 the 6 digits 4..9 must be placed below the 1,2,3. Randomize
-	// their positions.
-	restBox1 := [6]int{4, 5, 6, 7, 8, 9}
-	shuffle(restBox1[:])
-	copy(game[9:], restBox1[:3])
-	copy(game[18:], restBox1[3:])
-
-	// With box 1 filled, we know which digits must go into the bottom 6 rows
-	// of column 1. They are the digits in box 1 which are not in column 1
-	// already. Randomize their positions.
-	restCol1 := [6]int{game[1], game[2], game[10], game[11], game[19], game[20]}
-	shuffle(restCol1[:])
-	game[27] = restCol1[0]
-	game[36] = restCol1[1]
-	game[45] = restCol1[2]
-	game[54] = restCol1[3]
-	game[63] = restCol1[4]
-	game[72] = restCol1[5]
-
-	// Now we have to place the numbers 1,2,3 in box 2 and 3, in the lower two
-	// rows. Randomize their positions.
-	box2 := [3]int{1, 2, 3}
-	box3 := box2
-	shuffle(box2[:])
-	shuffle(box3[:])
-
-	game[12+rand.Intn(2)*9] = box2[0]
-	game[13+rand.Intn(2)*9] = box2[1]
-	game[14+rand.Intn(2)*9] = box2[2]
-
-	if contains(game[12:15], box3[0]) {
-		game[24] = box3[0]
-	} else {
-		game[15] = box3[0]
-	}
-	if contains(game[12:15], box3[1]) {
-		game[25] = box3[1]
-	} else {
-		game[16] = box3[1]
-	}
-	if contains(game[12:15], box3[2]) {
-		game[26] = box3[2]
-	} else {
-		game[17] = box3[2]
-	}
+func wuiColor(c color.RGBA) wui.Color {
+	return wui.RGB(c.R, c.G, c.B)
+}
 
-	// The same as we did with 1,2,3 from box 1, row 1 has to happen for box 1,
-	// column 1. We place two sets of these digits randomly in column 2 and 3 of
-	// boxes 4 and 7.
-	box4 := [3]int{game[0], game[9], game[18]}
-	box7 := box4
-	shuffle(box4[:])
-	shuffle(box7[:])
+func (p canvasPainter) FillRect(x, y, width, height int, color color.RGBA) {
+	p.canvas.FillRect(x, y, width, height, wuiColor(color))
+}
 
-	game[28+rand.Intn(2)] = box4[0]
-	game[37+rand.Intn(2)] = box4[1]
-	game[46+rand.Intn(2)] = box4[2]
+func (p canvasPainter) FillEllipse(x, y, width, height int, color color.RGBA) {
+	p.canvas.FillEllipse(x, y, width, height, wuiColor(color))
+}
 
-	if contains([]int{game[28], game[37], game[46]}, box7[0]) {
-		game[56] = box7[0]
-	} else {
-		game[55] = box7[0]
-	}
-	if contains([]int{game[28], game[37], game[46]}, box7[1]) {
-		game[65] = box7[1]
-	} else {
-		game[64] = box7[1]
-	}
-	if contains([]int{game[28], game[37], game[46]}, box7[2]) {
-		game[74] = box7[2]
-	} else {
-		game[71] = box7[2]
+func (p canvasPainter) Polygon(points []image.Point, color color.RGBA) {
+	corners := make([]wui.Point, len(points))
+	for i, q := range points {
+		corners[i] = wui.Point{X: int32(q.X), Y: int32(q.Y)}
 	}
+	p.canvas.Polygon(corners, wuiColor(color))
+}
 
-	// Chances are about 1 in 10 that this created a solvable game.
-	return sudoku.Solve(game)
+func (p canvasPainter) SetFont(font fontKind) {
+	p.canvas.SetFont(p.fonts[font])
 }
 
-func shuffle(x []int) {
-	for i := range x {
-		j := i + rand.Intn(len(x)-i)
-		x[i], x[j] = x[j], x[i]
-	}
+func (p canvasPainter) TextExtent(s string) (width, height int) {
+	return p.canvas.TextExtent(s)
+}
+
+func (p canvasPainter) TextOut(x, y int, s string, color color.RGBA) {
+	p.canvas.TextOut(x, y, s, wuiColor(color))
 }
 
-func contains(list []int, x int) bool {
-	in := false
-	for _, n := range list {
-		in = in || x == n
+// TextRectFormat wraps long lines, unlike the other painters.
+func (p canvasPainter) TextRectFormat(x, y, w, h int, s string, align textAlignment, color color.RGBA) {
+	format := wui.FormatCenter
+	if align == alignTopLeft {
+		format = wui.FormatTopLeft
 	}
-	return in
+	p.canvas.TextRectFormat(x, y, w, h, s, format, wuiColor(color))
 }
 
-func swapLines(g *sudoku.Game, a, b int) {
-	if a != b {
-		aa := a * 9
-		bb := b * 9
-		for i := 0; i < 9; i++ {
-			g[aa+i], g[bb+i] = g[bb+i], g[aa+i]
+func screenToBoard(x, y int) (col, row int) {
+	{
+		best := 9999999
+		for c := 0; c < gridShape.size; c++ {
+			cx, _ := tileTopLeft(c, 0)
+			cx += tileSize / 2
+			if abs(x-cx) < best {
+				col = c
+				best = abs(x - cx)
+			}
 		}
 	}
-}
 
-func swapCols(g *sudoku.Game, a, b int) {
-	if a != b {
-		for i := 0; i < 9; i++ {
-			g[a+i*9], g[b+i*9] = g[b+i*9], g[a+i*9]
+	{
+		best := 9999999
+		for r := 0; r < gridShape.size; r++ {
+			_, cy := tileTopLeft(0, r)
+			cy += tileSize / 2
+			if abs(y-cy) < best {
+				row = r
+				best = abs(y - cy)
+			}
 		}
 	}
+
+	return
 }
 
 func copyTextToClipboard(text string) {
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// The game uses the Windows API for its user interface. On other systems only
+// the solver, the file formats and the drawing of the board compile, e.g. for
+// running the tests.
+func main() {
+	fmt.Fprintln(os.Stderr, "Soduko only runs on Windows.")
+	os.Exit(1)
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+)
+
+// lineClue is a clue outside the grid. It is about the cells in a straight
+// line from the clue into the grid.
+type lineClue struct {
+	// cells are the cells in the line, the one next to the clue first.
+	cells []int
+	value int
+}
+
+// sideLines returns the rows, seen from the left, and the columns, seen from
+// the top of the grid.
+func sideLines() [][]int {
+	var lines [][]int
+	for i := 0; i < 9; i++ {
+		var row, col []int
+		for j := 0; j < 9; j++ {
+			row = append(row, 9*i+j)
+			col = append(col, i+9*j)
+		}
+		lines = append(lines, row, col)
+	}
+	return lines
+}
+
+// allSideLines returns the rows and columns seen from all four sides of the
+// grid.
+func allSideLines() [][]int {
+	var lines [][]int
+	for _, line := range sideLines() {
+		reversed := make([]int, len(line))
+		for k, i := range line {
+			reversed[len(line)-1-k] = i
+		}
+		lines = append(lines, line, reversed)
+	}
+	return lines
+}
+
+// diagonalLines returns the diagonals of the grid which are at least two cells
+// long, each seen from a random one of its ends.
+func diagonalLines() [][]int {
+	inside := func(col, row int) bool {
+		return 0 <= col && col < 9 && 0 <= row && row < 9
+	}
+	var lines [][]int
+	// Going down from the top and either side finds every diagonal once.
+	for _, d := range [][2]int{{1, 1}, {-1, 1}} {
+		for i := 0; i < 81; i++ {
+			col, row := i%9, i/9
+			if inside(col-d[0], row-d[1]) || !inside(col+d[0], row+d[1]) {
+				// The line must start at the border and be longer than
+				// one cell.
+				continue
+			}
+			var line []int
+			for ; inside(col, row); col, row = col+d[0], row+d[1] {
+				line = append(line, col+9*row)
+			}
+			if rand.Intn(2) == 0 {
+				for a, b := 0, len(line)-1; a < b; a, b = a+1, b-1 {
+					line[a], line[b] = line[b], line[a]
+				}
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// clueHints returns hints of the given kind for the clues, which write their
+// values outside the grid.
+func clueHints(clues []lineClue, kind hintKind) []hint {
+	var hints []hint
+	for _, c := range clues {
+		hints = append(hints, hint{
+			kind:  kind,
+			cells: c.cells,
+			text:  strconv.Itoa(c.value),
+		})
+	}
+	return hints
+}
+
+// lineCluesSymmetricUnder returns true if t moves every clue's line onto the
+// line of a clue with the same value. If directed is false, the direction of
+// the lines does not matter.
+func lineCluesSymmetricUnder(clues []lineClue, t transform, directed bool) bool {
+	key := func(cells []int, value int) string {
+		if directed {
+			return fmt.Sprint(cells, value)
+		}
+		return cellSetKey(cells) + strconv.Itoa(value)
+	}
+	have := make(map[string]bool)
+	for _, c := range clues {
+		have[key(c.cells, c.value)] = true
+	}
+	for _, c := range clues {
+		moved := make([]int, len(c.cells))
+		for j, i := range c.cells {
+			col, row := t.target(i%9, i/9)
+			moved[j] = col + 9*row
+		}
+		if !have[key(moved, c.value)] {
+			return false
+		}
+	}
+	return true
+}
+
+// newLineClueGame generates a game with clues outside the grid. A clue is
+// computed by value for each of the lines of a random solution. Givens are
+// removed as long as the game stays unique and after that as many clues as
+// possible. newConstraint creates the constraint for a list of clues.
+func newLineClueGame(
+	lines [][]int,
+	value func(solution grid, line []int) int,
+	newConstraint func([]lineClue) constraint,
+	extra rules,
+	givenDigits int,
+) (rules, grid, error) {
+	r := append(classicRules(), extra...)
+	solution, err := r.randomSolution()
+	if err != nil {
+		return r, solution, err
+	}
+
+	var clues []lineClue
+	for _, line := range lines {
+		clues = append(clues, lineClue{cells: line, value: value(solution, line)})
+	}
+	start := removeGivens(append(r, newConstraint(clues)), solution, givenDigits)
+
+	// Drop clues which are not needed for a unique solution.
+	kept := func(dropped []bool) []lineClue {
+		var kept []lineClue
+		for k, c := range clues {
+			if !dropped[k] {
+				kept = append(kept, c)
+			}
+		}
+		return kept
+	}
+	dropped := removeClues(start, len(clues), func(dropped []bool) rules {
+		return append(r, newConstraint(kept(dropped)))
+	})
+	return append(r, newConstraint(kept(dropped))), start, nil
+}
+
+// outsideKind is a kind of clue outside the grid, other than sandwich clues.
+type outsideKind int
+
+const (
+	// littleKillerClue is the sum of the digits on its diagonal, digits may
+	// repeat on it.
+	littleKillerClue outsideKind = iota
+	// skyscraperClue is the number of digits seen from the clue, looking
+	// along the row or column, where larger digits hide smaller ones behind
+	// them.
+	skyscraperClue
+	// xSumClue is the sum of the first X digits of the row or column seen
+	// from the clue, where X is the first digit.
+	xSumClue
+)
+
+// lines returns the lines which can have a clue of this kind.
+func (k outsideKind) lines() [][]int {
+	if k == littleKillerClue {
+		return diagonalLines()
+	}
+	return allSideLines()
+}
+
+// value returns the clue of this kind for a full line.
+func (k outsideKind) value(solution grid, line []int) int {
+	switch k {
+	case littleKillerClue:
+		sum := 0
+		for _, i := range line {
+			sum += solution[i]
+		}
+		return sum
+	case skyscraperClue:
+		seen, highest := 0, 0
+		for _, i := range line {
+			if solution[i] > highest {
+				seen, highest = seen+1, solution[i]
+			}
+		}
+		return seen
+	case xSumClue:
+		sum := 0
+		for _, i := range line[:solution[line[0]]] {
+			sum += solution[i]
+		}
+		return sum
+	}
+	return 0
+}
+
+// possible returns false if the empty cells of the clue's line cannot be
+// filled so that it matches the clue.
+func (k outsideKind) possible(g grid, clue lineClue) bool {
+	switch k {
+	case littleKillerClue:
+		return littleKillerPossible(g, clue)
+	case skyscraperClue:
+		return skyscraperPossible(g, clue)
+	case xSumClue:
+		return xSumPossible(g, clue)
+	}
+	return true
+}
+
+// directed returns true if it matters from which end of its line a clue of
+// this kind is seen.
+func (k outsideKind) directed() bool {
+	return k != littleKillerClue
+}
+
+// littleKillerPossible returns false if the digits on the diagonal cannot add
+// up to the clue. Digits may repeat on the diagonal, but not within a box.
+func littleKillerPossible(g grid, clue lineClue) bool {
+	least, most := 0, 0
+	line := clue.cells
+	for from := 0; from < len(line); {
+		// The diagonal runs through each box in one piece.
+		box := line[from]/27*3 + line[from]%9/3
+		to := from + 1
+		for to < len(line) && line[to]/27*3+line[to]%9/3 == box {
+			to++
+		}
+
+		var used [10]bool
+		empty := 0
+		for _, i := range line[from:to] {
+			used[g[i]] = true
+			least += g[i]
+			most += g[i]
+			if g[i] == 0 {
+				empty++
+			}
+		}
+		for n, count := 1, 0; n <= 9 && count < empty; n++ {
+			if !used[n] {
+				least += n
+				count++
+			}
+		}
+		for n, count := 9, 0; n >= 1 && count < empty; n-- {
+			if !used[n] {
+				most += n
+				count++
+			}
+		}
+		from = to
+	}
+	return least <= clue.value && clue.value <= most
+}
+
+// skyscraperPossible returns false if the number of digits seen along a row
+// or column cannot be the clue. It compares the clue to bounds for the
+// number: a digit is seen if it is larger than all digits before it.
+func skyscraperPossible(g grid, clue lineClue) bool {
+	line := clue.cells
+	// after[n] is 1 + the position of digit n in the line, 0 if it is not
+	// placed.
+	var after [10]int
+	for k, i := range line {
+		after[g[i]] = k + 1
+	}
+
+	// At most, every placed digit larger than the ones before it is seen and
+	// every empty cell for which a larger free digit is left.
+	most, highest := 0, 0
+	for _, i := range line {
+		if n := g[i]; n != 0 {
+			if n > highest {
+				most, highest = most+1, n
+			}
+			continue
+		}
+		for n := highest + 1; n <= 9; n++ {
+			if after[n] == 0 {
+				most++
+				break
+			}
+		}
+	}
+
+	// At least, the first cell is seen and every other digit whose larger
+	// digits are all placed after it.
+	least := 1
+	for k, i := range line[1:] {
+		n := g[i]
+		if n == 0 {
+			continue
+		}
+		seen := true
+		for m := n + 1; m <= 9 && seen; m++ {
+			seen = after[m] > k+2
+		}
+		if seen {
+			least++
+		}
+	}
+
+	return least <= clue.value && clue.value <= most
+}
+
+// xSumPossible returns false if no first digit X lets the first X digits of
+// the line add up to the clue. The digits of the line are all different.
+func xSumPossible(g grid, clue lineClue) bool {
+	line := clue.cells
+	var used [10]bool
+	for _, i := range line {
+		used[g[i]] = true
+	}
+
+	// fits returns true if the first x digits can add up to the clue when
+	// the first digit is x.
+	fits := func(x int) bool {
+		sum, empty := x, 0
+		for _, i := range line[1:x] {
+			sum += g[i]
+			if g[i] == 0 {
+				empty++
+			}
+		}
+		// Add the smallest and the largest free digits for the empty cells.
+		least, most := sum, sum
+		for n, count := 1, 0; n <= 9 && count < empty; n++ {
+			if !used[n] && n != x {
+				least += n
+				count++
+			}
+		}
+		count := 0
+		for n := 9; n >= 1 && count < empty; n-- {
+			if !used[n] && n != x {
+				most += n
+				count++
+			}
+		}
+		return count == empty && least <= clue.value && clue.value <= most
+	}
+
+	if x := g[line[0]]; x != 0 {
+		return fits(x)
+	}
+	for x := 1; x <= 9; x++ {
+		if !used[x] && fits(x) {
+			return true
+		}
+	}
+	return false
+}
+
+// outsideConstraint holds the clues of one kind outside the grid.
+type outsideConstraint struct {
+	kind  outsideKind
+	clues []lineClue
+	// cluesAt lists the clues whose line goes through each cell.
+	cluesAt [81][]int
+}
+
+func newOutsideConstraint(kind outsideKind, clues []lineClue) outsideConstraint {
+	c := outsideConstraint{kind: kind, clues: clues}
+	for k, clue := range clues {
+		for _, i := range clue.cells {
+			c.cluesAt[i] = append(c.cluesAt[i], k)
+		}
+	}
+	return c
+}
+
+func (c outsideConstraint) houses() [][]int {
+	return nil
+}
+
+func (c outsideConstraint) allows(g grid, i, n int) bool {
+	if len(c.cluesAt[i]) == 0 {
+		return true
+	}
+	// Try n in cell i, without copying the grid.
+	was := g[i]
+	g[i] = n
+	defer func() { g[i] = was }()
+	for _, k := range c.cluesAt[i] {
+		if !c.kind.possible(g, c.clues[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// conflicts returns the digits of the lines which cannot match their clue
+// anymore.
+func (c outsideConstraint) conflicts(g grid) [][]int {
+	var conflicts [][]int
+	for _, clue := range c.clues {
+		if c.kind.possible(g, clue) {
+			continue
+		}
+		var group []int
+		for _, i := range clue.cells {
+			if g[i] != 0 {
+				group = append(group, i)
+			}
+		}
+		conflicts = append(conflicts, group)
+	}
+	return conflicts
+}
+
+func (c outsideConstraint) hints() []hint {
+	if c.kind == littleKillerClue {
+		return clueHints(c.clues, littleKillerHint)
+	}
+	return clueHints(c.clues, clueHint)
+}
+
+// symmetricUnder returns true if t moves the clues onto each other. The clues
+// depend on the digits so they cannot be relabeled.
+func (c outsideConstraint) symmetricUnder(t transform) bool {
+	return t.digits == identityTransform().digits && lineCluesSymmetricUnder(c.clues, t, c.kind.directed())
+}
+
+// newOutsideGame creates a newGame function for sudokus with clues of the
+// given kind outside the grid, see newLineClueGame.
+func newOutsideGame(kind outsideKind) func(rules, int) (rules, grid, error) {
+	return func(extra rules, givenDigits int) (rules, grid, error) {
+		newConstraint := func(clues []lineClue) constraint {
+			return newOutsideConstraint(kind, clues)
+		}
+		return newLineClueGame(kind.lines(), kind.value, newConstraint, extra, givenDigits)
+	}
+}
@@ -0,0 +1,821 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+var (
+	// All sizes derive from the tile size, see setTileSize.
+	tileSize         = 90
+	thinBorderSize   = 3
+	thickBorderSize  = 3 * thinBorderSize
+	boardSize        = 4*thickBorderSize + 6*thinBorderSize + 9*tileSize
+	panelWidth       = 3*panelTileSize + 2*thinBorderSize + thickBorderSize
+	mediumFontHeight = tileSize / 2
+	// panelTileSize is the size of the digit buttons in the panel of a 9x9
+	// game. The panel keeps its size relative to the board in other shapes.
+	panelTileSize = tileSize
+
+	// gridShape is the shape of the current game. All loops over the board
+	// and its layout follow it.
+	gridShape = classicShape
+
+	// clueRing is true for games with clues outside the grid. The board then
+	// has a ring of clueMargin around it for them, otherwise clueMargin is 0.
+	clueRing   = false
+	clueMargin = 0
+
+	// boardX and boardY are the top-left corner of the board in the window.
+	// The board and panel are centered in the window, see layoutWindow.
+	boardX = 0
+	boardY = 0
+
+	backColor          = rgb(64, 64, 64)
+	hotColor           = rgb(64, 64, 192)
+	borderColor        = rgb(192, 192, 192)
+	textColor          = rgb(255, 255, 255)
+	fixedColor         = rgb(192, 192, 255)
+	highlightBackColor = rgb(92, 92, 64)
+	errorColor         = rgb(255, 96, 96)
+	shadeColor         = rgb(96, 96, 96)
+	lineColor          = rgb(128, 128, 128)
+	blackDotColor      = rgb(0, 0, 0)
+	whisperColor       = rgb(64, 176, 64)
+	renbanColor        = rgb(176, 96, 208)
+	palindromeColor    = rgb(96, 144, 208)
+
+	// markColors are the colors that cells can be marked with, e.g. for
+	// tracking chains or parity.
+	markColors = [9]color.RGBA{
+		rgb(160, 48, 48),
+		rgb(176, 104, 32),
+		rgb(150, 140, 40),
+		rgb(48, 136, 48),
+		rgb(32, 128, 128),
+		rgb(48, 80, 176),
+		rgb(120, 56, 160),
+		rgb(176, 72, 128),
+		rgb(112, 112, 112),
+	}
+)
+
+// rgb returns the opaque color with the given red, green and blue.
+func rgb(r, g, b uint8) color.RGBA {
+	return color.RGBA{R: r, G: g, B: b, A: 255}
+}
+
+// fontKind is one of the fonts that the board is drawn with. Their height
+// follows the tile size, see fontHeight.
+type fontKind int
+
+const (
+	largeFont fontKind = iota
+	mediumFont
+	smallFont
+	fontKindCount
+)
+
+// fontHeight is the height of a line of text in the given font at the
+// current tile size. The large font is for digits, the medium font for clues
+// and the small font for pencil marks and the panel.
+func fontHeight(f fontKind) int {
+	switch f {
+	case largeFont:
+		return tileSize - tileSize/10
+	case mediumFont:
+		return mediumFontHeight
+	}
+	return tileSize / 4
+}
+
+// wantHighlight returns true if all selected cells have the digit n, the
+// other cells with it are then highlighted.
+func wantHighlight(b board, n int) bool {
+	ok := false
+	for y := range b {
+		for x := range b {
+			if b[x][y].hot {
+				ok = true
+				if b[x][y].number != n {
+					return false
+				}
+			}
+		}
+	}
+	return ok
+}
+
+// paintBoard draws the grids of the game with the constraints, digits and
+// marks. The window paints it next to the panel, exportImage paints it
+// alone into an image. newLine are the cells of the arrow or line which is
+// being drawn in setter mode, arrow tells which of the two it is.
+func paintBoard(canvas painter, b board, r rules, newLine []int, arrow bool) {
+	for _, g := range gridShape.grids() {
+		x, y, w, h := gridFrame(g)
+		canvas.FillRect(x, y, w, h, borderColor)
+	}
+
+	hints := r.hints()
+	shaded := make([]bool, gridShape.size*gridShape.size)
+	for _, h := range hints {
+		if h.kind == shadeHint {
+			for _, i := range h.cells {
+				shaded[i] = true
+			}
+		}
+	}
+
+	// Draw the cell backgrounds first, the variant's lines go on top
+	// of them and the digits on top of everything.
+	background := make([]color.RGBA, gridShape.size*gridShape.size)
+	for row := 0; row < gridShape.size; row++ {
+		for col := 0; col < gridShape.size; col++ {
+			if gridShape.hole(col + gridShape.size*row) {
+				continue
+			}
+			f := b[col][row]
+			x, y := tileTopLeft(col, row)
+
+			back := backColor
+			if shaded[col+gridShape.size*row] {
+				back = shadeColor
+			}
+			if f.hot {
+				back = hotColor
+			}
+			if f.number > 0 && wantHighlight(b, f.number) {
+				back = highlightBackColor
+			}
+			canvas.FillRect(x, y, tileSize, tileSize, back)
+			background[col+gridShape.size*row] = back
+
+			// Draw the color marks. Selected cells keep a frame of
+			// their background color so the selection stays visible.
+			{
+				var colors []color.RGBA
+				for i := range f.colors {
+					if f.colors[i] {
+						colors = append(colors, markColors[i])
+					}
+				}
+				cx, cy, size := x, y, tileSize
+				if f.hot {
+					inset := tileSize / 8
+					cx, cy, size = x+inset, y+inset, tileSize-2*inset
+				}
+				for i, c := range colors {
+					if len(colors) == 1 {
+						canvas.FillRect(cx, cy, size, size, c)
+					} else {
+						canvas.Polygon(colorSegment(cx, cy, size, i, len(colors)), c)
+					}
+				}
+			}
+		}
+	}
+
+	if r.hasHint(regionHint) {
+		drawRegions(canvas, r.regions(), background)
+	}
+
+	for _, h := range hints {
+		if h.kind == evenHint || h.kind == oddHint {
+			// A gray square for even and a gray circle for odd
+			// digits, with a light outline.
+			x, y := tileTopLeft(h.cells[0]%gridShape.size, h.cells[0]/gridShape.size)
+			inset := tileSize / 5
+			x, y, size := x+inset, y+inset, tileSize-2*inset
+			in := thinBorderSize
+			if h.kind == evenHint {
+				canvas.FillRect(x, y, size, size, borderColor)
+				canvas.FillRect(x+in, y+in, size-2*in, size-2*in, shadeColor)
+			} else {
+				canvas.FillEllipse(x, y, size, size, borderColor)
+				canvas.FillEllipse(x+in, y+in, size-2*in, size-2*in, shadeColor)
+			}
+		}
+		if h.kind == lineHint {
+			drawThickLine(canvas, centerPoints(h.cells), tileSize/12, lineColor)
+		}
+		if h.kind == whisperHint || h.kind == renbanHint || h.kind == palindromeHint {
+			color := whisperColor
+			if h.kind == renbanHint {
+				color = renbanColor
+			} else if h.kind == palindromeHint {
+				color = palindromeColor
+			}
+			drawThickLine(canvas, centerPoints(h.cells), tileSize/6, color)
+		}
+		if h.kind == thermoHint {
+			drawThickLine(canvas, centerPoints(h.cells), tileSize/4, lineColor)
+			bulb := tileSize * 2 / 3
+			x, y := cellCenter(h.cells[0])
+			canvas.FillEllipse(x-bulb/2, y-bulb/2, bulb, bulb, lineColor)
+		}
+		if h.kind == arrowHint {
+			drawArrow(canvas, h.cells, lineColor, background)
+		}
+		if h.kind == clueHint || h.kind == littleKillerHint {
+			// The clue is one step before the first cell, seen from
+			// the second one.
+			x1, y1 := cellCenter(h.cells[0])
+			x2, y2 := cellCenter(h.cells[1])
+			x, y := 2*x1-x2, 2*y1-y2
+			if h.kind == littleKillerHint {
+				// The text moves back to make room for the arrow,
+				// which points at the first cell.
+				dx, dy := x1-x, y1-y
+				drawLittleKillerArrow(canvas, x+dx/8, y+dy/8, x+dx*2/5, y+dy*2/5, textColor)
+				x, y = x-dx/6, y-dy/6
+			}
+			canvas.SetFont(mediumFont)
+			w, th := canvas.TextExtent(h.text)
+			canvas.TextOut(x-w/2, y-th/2, h.text, textColor)
+		}
+		if h.kind == whiteDotHint || h.kind == blackDotHint {
+			// The dots reach into both cells. The black dot keeps a
+			// white outline so it stands out from dark cells.
+			x, y := edgeCenter(h.cells[0], h.cells[1])
+			size := tileSize / 4
+			canvas.FillEllipse(x-size/2, y-size/2, size, size, textColor)
+			if h.kind == blackDotHint {
+				inner := size - 2*thinBorderSize
+				canvas.FillEllipse(x-inner/2, y-inner/2, inner, inner, blackDotColor)
+			}
+		}
+		if h.kind == greaterHint {
+			// The sign points at the second cell, which has the
+			// smaller digit.
+			x, y := edgeCenter(h.cells[0], h.cells[1])
+			x1, y1 := cellCenter(h.cells[0])
+			x2, y2 := cellCenter(h.cells[1])
+			dx, dy := sign(x2-x1), sign(y2-y1)
+			s := tileSize / 10
+			drawThickLine(canvas, []image.Point{
+				{X: x - s*dx + s*dy, Y: y - s*dy + s*dx},
+				{X: x + s*dx, Y: y + s*dy},
+				{X: x - s*dx - s*dy, Y: y - s*dy - s*dx},
+			}, tileSize/20, borderColor)
+		}
+		if h.kind == edgeTextHint {
+			x, y := edgeCenter(h.cells[0], h.cells[1])
+			canvas.SetFont(smallFont)
+			w, th := canvas.TextExtent(h.text)
+			canvas.FillRect(x-w/2, y-th/2, w, th, background[h.cells[0]])
+			canvas.TextOut(x-w/2, y-th/2, h.text, textColor)
+		}
+		if h.kind == cageHint {
+			drawCage(canvas, h.cells, textColor)
+
+			// The sum goes into the first cell, over the outline.
+			first := h.cells[0]
+			for _, i := range h.cells {
+				if i < first {
+					first = i
+				}
+			}
+			x, y := tileTopLeft(first%gridShape.size, first/gridShape.size)
+			canvas.SetFont(smallFont)
+			w, th := canvas.TextExtent(h.text)
+			canvas.FillRect(x+tileSize/20, y+tileSize/20, w, th, background[first])
+			canvas.TextOut(x+tileSize/20, y+tileSize/20, h.text, textColor)
+		}
+	}
+
+	if len(newLine) > 0 && arrow {
+		drawArrow(canvas, newLine, hotColor, background)
+	} else if len(newLine) > 0 {
+		drawThickLine(canvas, centerPoints(newLine), tileSize/6, hotColor)
+	}
+
+	// Digits which break a chess rule are not in the same house, a
+	// line connects them so they are easy to find.
+	game := b.game()
+	for _, c := range r {
+		if chess, ok := c.(chessConstraint); ok {
+			for _, pair := range chess.conflicts(game) {
+				x1, y1 := cellCenter(pair[0])
+				x2, y2 := cellCenter(pair[1])
+				drawThickLine(canvas, []image.Point{
+					{X: x1, Y: y1},
+					{X: x2, Y: y2},
+				}, tileSize/20, errorColor)
+			}
+		}
+	}
+
+	conflicts := r.conflicts(game)
+	for row := 0; row < gridShape.size; row++ {
+		for col := 0; col < gridShape.size; col++ {
+			f := b[col][row]
+			x, y := tileTopLeft(col, row)
+
+			if f.number > 0 {
+				text := digitName(f.number)
+				canvas.SetFont(largeFont)
+				w, h := canvas.TextExtent(text)
+				color := textColor
+				if f.fixed {
+					color = fixedColor
+				}
+				if conflicts[col+gridShape.size*row] {
+					color = errorColor
+				}
+				canvas.TextOut(x+(tileSize-w)/2, y+(tileSize-h)/2, text, color)
+			} else {
+				// Draw pencil marks.
+				// Draw the corner marks.
+				canvas.SetFont(smallFont)
+				for i := 0; i < gridShape.digits(); i++ {
+					if f.corner[i] {
+						text := digitName(i + 1)
+						w, h := canvas.TextExtent(text)
+						bx, by, bw, bh := cornerPencilMarkBounds(i)
+						canvas.TextOut(x+bx+(bw-w)/2, y+by+(bh-h)/2, text, textColor)
+					}
+				}
+				// Draw the center marks.
+				var centerText string
+				for i := 0; i < gridShape.digits(); i++ {
+					if f.center[i] {
+						centerText += digitName(i + 1)
+					}
+				}
+				half := len(centerText) / 2
+				if half >= 3 {
+					centerText = centerText[:half] + "\n" + centerText[half:]
+				}
+				canvas.TextRectFormat(x, y, tileSize, tileSize, centerText, alignCenter, textColor)
+			}
+		}
+	}
+}
+
+// minTileSize is the smallest tile size that layoutWindow will use. The board
+// scrolls in smaller windows.
+const minTileSize = 10
+
+// setTileSize sets the tile size and all sizes derived from it.
+func setTileSize(size int) {
+	tileSize = size
+	thinBorderSize = tileSize / 30
+	if thinBorderSize < 1 {
+		thinBorderSize = 1
+	}
+	thickBorderSize = 3 * thinBorderSize
+	boardSize = boardExtent(gridShape.size / gridShape.boxWidth)
+	if down := boardExtent(gridShape.size / gridShape.boxHeight); down > boardSize {
+		boardSize = down
+	}
+	panelTileSize = tileSize * gridShape.size / 9
+	panelWidth = 3*panelTileSize + 2*thinBorderSize + thickBorderSize
+	mediumFontHeight = tileSize / 2
+	clueMargin = 0
+	if clueRing {
+		clueMargin = tileSize
+	}
+}
+
+// gridFrame returns the area of the board behind the grid whose top-left cell
+// is in the given column and row, including its outer border.
+func gridFrame(topLeft [2]int) (x, y, w, h int) {
+	n := gridShape.digits()
+	col, row := topLeft[0], topLeft[1]
+	left, top := tileTopLeft(col, row)
+	right, bottom := tileTopLeft(col+n-1, row+n-1)
+	left, top = left-thickBorderSize, top-thickBorderSize
+	right, bottom = right+tileSize+thickBorderSize, bottom+tileSize+thickBorderSize
+	// Grids at the edges of the board reach to its edges, like the frame of a
+	// single grid.
+	if col == 0 {
+		left = boardX
+	}
+	if row == 0 {
+		top = boardY
+	}
+	if col+n == gridShape.size {
+		right = boardX + boardSize
+	}
+	if row+n == gridShape.size {
+		bottom = boardY + boardSize
+	}
+	return left, top, right - left, bottom - top
+}
+
+// boardExtent is the width of the tiles and borders of a row of the board, or
+// the height of a column, which is crossed by the given number of boxes. Boxes
+// which are not square make this different for rows and columns, the board is
+// as large as the larger one so it stays square.
+func boardExtent(boxes int) int {
+	n := gridShape.size
+	return (boxes+1)*thickBorderSize + (n-boxes)*thinBorderSize + n*tileSize
+}
+
+// layoutImage chooses the largest tile size for which the board and its clue
+// ring fit into a square image of the given size. The board is centered in
+// the image.
+func layoutImage(size int) {
+	tile := size / gridShape.size
+	for {
+		setTileSize(tile)
+		if tile <= minTileSize || boardSize+2*clueMargin <= size {
+			break
+		}
+		tile--
+	}
+	boardX = (size - boardSize) / 2
+	boardY = boardX
+}
+
+// tileTopLeft returns the top-left corner of the tile in the given column
+// and row. The rows or columns crossed by fewer boxes are centered on the
+// board, see boardExtent.
+func tileTopLeft(col, row int) (x, y int) {
+	w, h := gridShape.boxWidth, gridShape.boxHeight
+	x = boardX + (boardSize-boardExtent(gridShape.size/w))/2 + thinBorderSize +
+		(1+col/w)*(thickBorderSize-thinBorderSize) + col*(thinBorderSize+tileSize)
+	y = boardY + (boardSize-boardExtent(gridShape.size/h))/2 + thinBorderSize +
+		(1+row/h)*(thickBorderSize-thinBorderSize) + row*(thinBorderSize+tileSize)
+	return
+}
+
+// cornerPencilMarkBounds returns where corner mark i goes in a tile. Up to 9
+// marks go around the border of the tile, larger grids put their marks into a
+// 4x4 raster.
+func cornerPencilMarkBounds(i int) (x, y, w, h int) {
+	if gridShape.digits() > 9 {
+		w, h = tileSize/4, tileSize/4
+		return i % 4 * w, i / 4 * h, w, h
+	}
+
+	switch i {
+	case 0, 1, 2, 3, 4, 5, 6, 8:
+		w = tileSize / 4
+	case 7:
+		w = tileSize / 3
+	}
+
+	switch i {
+	case 0, 1, 2, 3:
+		x = i * w
+	case 4, 6:
+		x = 0
+	case 5, 8:
+		x = 3 * w
+	case 7:
+		x = w
+	}
+
+	h = tileSize / 3
+	switch i {
+	case 0, 1, 2, 3:
+		y = 0
+	case 4, 5:
+		y = h
+	case 6, 7, 8:
+		y = 2 * h
+	}
+
+	return
+}
+
+// colorSegment returns the polygon for color number i of count colors in the
+// square tile at x,y. The tile is cut into count equal pie slices around its
+// center, starting at the top left corner and going clockwise.
+func colorSegment(x, y, size, i, count int) []image.Point {
+	// Positions along the tile's border are measured in units of size,
+	// starting at the top left corner, going clockwise around the tile, so the
+	// whole border has length 4.
+	borderPoint := func(pos float64) image.Point {
+		side := int(pos)
+		t := pos - float64(side)
+		var fx, fy float64
+		switch side % 4 {
+		case 0:
+			fx, fy = t, 0
+		case 1:
+			fx, fy = 1, t
+		case 2:
+			fx, fy = 1-t, 1
+		case 3:
+			fx, fy = 0, 1-t
+		}
+		return image.Point{
+			X: x + int(fx*float64(size)+0.5),
+			Y: y + int(fy*float64(size)+0.5),
+		}
+	}
+
+	from := 4 * float64(i) / float64(count)
+	to := 4 * float64(i+1) / float64(count)
+	p := []image.Point{{X: x + size/2, Y: y + size/2}, borderPoint(from)}
+	for corner := math.Floor(from) + 1; corner < to; corner++ {
+		p = append(p, borderPoint(corner))
+	}
+	return append(p, borderPoint(to))
+}
+
+// cellCenter returns the screen position of the center of cell i, cells are
+// numbered like in a grid.
+func cellCenter(i int) (x, y int) {
+	x, y = tileTopLeft(i%gridShape.size, i/gridShape.size)
+	return x + tileSize/2, y + tileSize/2
+}
+
+// centerPoints returns the centers of the cells, to draw a line through them.
+func centerPoints(cells []int) []image.Point {
+	var points []image.Point
+	for _, i := range cells {
+		x, y := cellCenter(i)
+		points = append(points, image.Point{X: x, Y: y})
+	}
+	return points
+}
+
+// edgeCenter is the middle of the border between the neighboring cells i and
+// j, half way between their centers.
+func edgeCenter(i, j int) (x, y int) {
+	x1, y1 := cellCenter(i)
+	x2, y2 := cellCenter(j)
+	return (x1 + x2) / 2, (y1 + y2) / 2
+}
+
+// drawThickLine draws a line through the given points. Windows draws lines
+// with a width of 1 so we draw thick lines as polygons and round the joints
+// with circles.
+func drawThickLine(canvas painter, points []image.Point, width int, color color.RGBA) {
+	r := float64(width) / 2
+	for i := range points {
+		p := points[i]
+		canvas.FillEllipse(p.X-width/2, p.Y-width/2, width, width, color)
+		if i == 0 {
+			continue
+		}
+		q := points[i-1]
+		dx, dy := float64(p.X-q.X), float64(p.Y-q.Y)
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		// n is perpendicular to the line, with length r.
+		nx, ny := int(math.Round(-dy/length*r)), int(math.Round(dx/length*r))
+		canvas.Polygon([]image.Point{
+			{X: q.X + nx, Y: q.Y + ny},
+			{X: p.X + nx, Y: p.Y + ny},
+			{X: p.X - nx, Y: p.Y - ny},
+			{X: q.X - nx, Y: q.Y - ny},
+		}, color)
+	}
+}
+
+// drawRegions draws the borders of irregular regions. The board's layout has
+// thick gaps between the boxes and thin gaps everywhere else. Gaps inside a
+// region are redrawn as thin lines and thick lines are drawn over the gaps
+// between different regions. Regions only exist in 9x9 games.
+func drawRegions(canvas painter, regions [81]int, background []color.RGBA) {
+	// gap returns the start and end of the gap after column or row i, i is
+	// -1 for the border in front of the first one.
+	gap := func(i int) (from, to int) {
+		if i == -1 {
+			x, _ := tileTopLeft(0, 0)
+			return boardX, x
+		}
+		x, _ := tileTopLeft(i, 0)
+		if i == 8 {
+			return x + tileSize, boardX + boardSize
+		}
+		next, _ := tileTopLeft(i+1, 0)
+		return x + tileSize, next
+	}
+	// thin returns where the thin line goes in the gap.
+	thin := func(from, to int) int {
+		return from + (to-from-thinBorderSize)/2
+	}
+	// thick returns where the thick line goes in the gap.
+	thick := func(from, to int) int {
+		return from + (to-from-thickBorderSize)/2
+	}
+	// The board is square so the gaps are the same for rows and columns,
+	// only boardX and boardY differ.
+	dy := boardY - boardX
+
+	for row := 0; row < 9; row++ {
+		for col := 0; col < 9; col++ {
+			i := col + 9*row
+			x, y := tileTopLeft(col, row)
+			if col < 8 && regions[i] == regions[i+1] {
+				from, to := gap(col)
+				mid := thin(from, to)
+				canvas.FillRect(from, y, mid-from, tileSize, background[i])
+				canvas.FillRect(mid, y, to-mid, tileSize, background[i+1])
+				canvas.FillRect(mid, y, thinBorderSize, tileSize, borderColor)
+			}
+			if row < 8 && regions[i] == regions[i+9] {
+				from, to := gap(row)
+				from, to = from+dy, to+dy
+				mid := thin(from, to)
+				canvas.FillRect(x, from, tileSize, mid-from, background[i])
+				canvas.FillRect(x, mid, tileSize, to-mid, background[i+9])
+				canvas.FillRect(x, mid, tileSize, thinBorderSize, borderColor)
+			}
+			if col < 8 && row < 8 && regions[i] == regions[i+1] &&
+				regions[i] == regions[i+9] && regions[i] == regions[i+10] {
+				// The lines cross inside the region.
+				x1, x2 := gap(col)
+				y1, y2 := gap(row)
+				y1, y2 = y1+dy, y2+dy
+				canvas.FillRect(x1, y1, x2-x1, y2-y1, background[i])
+				canvas.FillRect(thin(x1, x2), y1, thinBorderSize, y2-y1, borderColor)
+				canvas.FillRect(x1, thin(y1, y2), x2-x1, thinBorderSize, borderColor)
+			}
+		}
+	}
+
+	// The thick lines run over the gaps at both of their ends so they join
+	// where the border between regions turns.
+	for row := 0; row < 9; row++ {
+		for col := 0; col < 9; col++ {
+			i := col + 9*row
+			if col < 8 && regions[i] != regions[i+1] {
+				from, to := gap(col)
+				y1, _ := gap(row - 1)
+				_, y2 := gap(row)
+				canvas.FillRect(thick(from, to), y1+dy, thickBorderSize, y2-y1, borderColor)
+			}
+			if row < 8 && regions[i] != regions[i+9] {
+				from, to := gap(row)
+				x1, _ := gap(col - 1)
+				_, x2 := gap(col)
+				canvas.FillRect(x1, thick(from, to)+dy, x2-x1, thickBorderSize, borderColor)
+			}
+		}
+	}
+}
+
+// drawArrow draws an arrow with its circle in the first of the cells, going
+// through the centers of the other cells. The circle is filled with the
+// background color of its cell.
+func drawArrow(canvas painter, cells []int, color color.RGBA, background []color.RGBA) {
+	width := tileSize / 20
+	if width < 1 {
+		width = 1
+	}
+	size := tileSize * 4 / 5
+	x, y := cellCenter(cells[0])
+	canvas.FillEllipse(x-size/2, y-size/2, size, size, color)
+	inner := size - 2*width
+	canvas.FillEllipse(x-inner/2, y-inner/2, inner, inner, background[cells[0]])
+	if len(cells) < 2 {
+		return
+	}
+
+	// The line starts at the circle.
+	var points []image.Point
+	for _, i := range cells {
+		x, y := cellCenter(i)
+		points = append(points, image.Point{X: x, Y: y})
+	}
+	dx, dy := float64(points[1].X-points[0].X), float64(points[1].Y-points[0].Y)
+	length := math.Hypot(dx, dy)
+	r := float64(size) / 2
+	points[0].X += int(math.Round(dx / length * r))
+	points[0].Y += int(math.Round(dy / length * r))
+	drawThickLine(canvas, points, width, color)
+
+	drawArrowHead(canvas, points[len(points)-2], points[len(points)-1], tileSize/4, width, color)
+}
+
+// drawArrowHead draws the head of an arrow that goes from q to p, two short
+// lines of the given length at 45 degrees to the arrow.
+func drawArrowHead(canvas painter, q, p image.Point, length, width int, color color.RGBA) {
+	dx, dy := float64(p.X-q.X), float64(p.Y-q.Y)
+	d := math.Hypot(dx, dy)
+	for _, angle := range []float64{math.Pi * 3 / 4, -math.Pi * 3 / 4} {
+		sin, cos := math.Sincos(angle)
+		hx := (dx*cos - dy*sin) / d * float64(length)
+		hy := (dx*sin + dy*cos) / d * float64(length)
+		drawThickLine(canvas, []image.Point{
+			p,
+			{X: p.X + int(math.Round(hx)), Y: p.Y + int(math.Round(hy))},
+		}, width, color)
+	}
+}
+
+// drawLittleKillerArrow draws the small arrow from x1,y1 to x2,y2 which
+// shows the direction of a little killer clue.
+func drawLittleKillerArrow(canvas painter, x1, y1, x2, y2 int, color color.RGBA) {
+	width := tileSize / 30
+	if width < 1 {
+		width = 1
+	}
+	q := image.Point{X: x1, Y: y1}
+	p := image.Point{X: x2, Y: y2}
+	drawThickLine(canvas, []image.Point{q, p}, width, color)
+	drawArrowHead(canvas, q, p, tileSize/8, width, color)
+}
+
+// drawCage draws a dashed outline around the given cells. The outline is
+// inset into the tiles so the cages of neighboring cells stay apart.
+func drawCage(canvas painter, cells []int, color color.RGBA) {
+	n := gridShape.size
+	in := func(col, row int) bool {
+		for _, i := range cells {
+			if col >= 0 && col < n && row >= 0 && row < n && i == col+n*row {
+				return true
+			}
+		}
+		return false
+	}
+
+	inset := tileSize / 10
+	width := tileSize / 45
+	if width < 1 {
+		width = 1
+	}
+
+	for _, i := range cells {
+		col, row := i%n, i/n
+		x, y := tileTopLeft(col, row)
+
+		// An edge of the outline runs along each side of the cell which does
+		// not border the cage. It continues into the neighbor cells if they
+		// are in the cage, up to their outline at inner corners.
+		for _, d := range []int{-1, 1} {
+			if !in(col, row+d) {
+				ly := y + inset
+				if d == 1 {
+					ly = y + tileSize - inset - width
+				}
+				x1, x2 := x+inset, x+tileSize-inset
+				if in(col-1, row) {
+					lx, _ := tileTopLeft(col-1, row)
+					x1 = lx + tileSize
+					if in(col-1, row+d) {
+						x1 -= inset + width
+					}
+				}
+				if in(col+1, row) {
+					rx, _ := tileTopLeft(col+1, row)
+					x2 = rx
+					if in(col+1, row+d) {
+						x2 += inset + width
+					}
+				}
+				drawDashes(canvas, x1, ly, x2-x1, width, true, color)
+			}
+
+			if !in(col+d, row) {
+				lx := x + inset
+				if d == 1 {
+					lx = x + tileSize - inset - width
+				}
+				y1, y2 := y+inset, y+tileSize-inset
+				if in(col, row-1) {
+					_, ty := tileTopLeft(col, row-1)
+					y1 = ty + tileSize
+					if in(col+d, row-1) {
+						y1 -= inset + width
+					}
+				}
+				if in(col, row+1) {
+					_, by := tileTopLeft(col, row+1)
+					y2 = by
+					if in(col+d, row+1) {
+						y2 += inset + width
+					}
+				}
+				drawDashes(canvas, lx, y1, y2-y1, width, false, color)
+			}
+		}
+	}
+}
+
+// drawDashes draws a dashed horizontal or vertical line of the given length,
+// starting at x,y. The dashes are aligned to the screen, not to the start of
+// the line, so lines that continue each other look like a single line.
+func drawDashes(canvas painter, x, y, length, width int, horizontal bool, color color.RGBA) {
+	dash := tileSize / 12
+	if dash < 2 {
+		dash = 2
+	}
+	start := x
+	if !horizontal {
+		start = y
+	}
+	for from := start; from < start+length; {
+		to := (from/dash + 1) * dash
+		if to > start+length {
+			to = start + length
+		}
+		if from/dash%2 == 0 {
+			if horizontal {
+				canvas.FillRect(from, y, to-from, width, color)
+			} else {
+				canvas.FillRect(x, from, width, to-from, color)
+			}
+		}
+		from = to
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+	"strings"
+)
+
+// painter is what the board is drawn on. The window draws on its wui.Canvas
+// through a canvasPainter, exporting the board draws on an imagePainter or an
+// svgPainter.
+type painter interface {
+	FillRect(x, y, width, height int, color color.RGBA)
+	FillEllipse(x, y, width, height int, color color.RGBA)
+	Polygon(p []image.Point, color color.RGBA)
+	SetFont(font fontKind)
+	TextExtent(s string) (width, height int)
+	TextOut(x, y int, s string, color color.RGBA)
+	TextRectFormat(x, y, w, h int, s string, align textAlignment, color color.RGBA)
+}
+
+// textAlignment is where TextRectFormat puts the text in its rectangle.
+type textAlignment int
+
+const (
+	alignCenter textAlignment = iota
+	alignTopLeft
+)
+
+// imagePainter draws into an image, in pure Go so it works without a window.
+// Shapes are filled like on the canvas but with smooth edges. Text is drawn
+// with a simple stroke font which only knows the characters that appear on
+// the board: digits, the letters A to G for large grids and X and V. Only the
+// height of the fonts is used.
+type imagePainter struct {
+	img  *image.RGBA
+	font fontKind
+}
+
+func newImagePainter(width, height int) *imagePainter {
+	return &imagePainter{img: image.NewRGBA(image.Rect(0, 0, width, height))}
+}
+
+func (p *imagePainter) FillRect(x, y, width, height int, color color.RGBA) {
+	r := image.Rect(x, y, x+width, y+height)
+	draw.Draw(p.img, r, image.NewUniform(color), image.Point{}, draw.Src)
+}
+
+func (p *imagePainter) FillEllipse(x, y, width, height int, color color.RGBA) {
+	rx, ry := float64(width)/2, float64(height)/2
+	p.fill([][]vec{ellipse(float64(x)+rx, float64(y)+ry, rx, ry)}, color)
+}
+
+func (p *imagePainter) Polygon(points []image.Point, color color.RGBA) {
+	polygon := make([]vec, len(points))
+	for i, q := range points {
+		polygon[i] = vec{float64(q.X), float64(q.Y)}
+	}
+	p.fill([][]vec{polygon}, color)
+}
+
+func (p *imagePainter) SetFont(font fontKind) {
+	p.font = font
+}
+
+// fontHeight is the height of a line of text in the current font.
+func (p *imagePainter) fontHeight() float64 {
+	return float64(fontHeight(p.font))
+}
+
+func (p *imagePainter) TextExtent(s string) (width, height int) {
+	return textExtent(p.fontHeight(), s)
+}
+
+func (p *imagePainter) TextOut(x, y int, s string, color color.RGBA) {
+	h := p.fontHeight()
+	// The glyphs sit on the baseline of the line of text, their tops where
+	// the capital letters of the font start.
+	top, size := float64(y)+0.23*h, 0.6*h
+	left := float64(x)
+	var polygons [][]vec
+	for _, r := range s {
+		advance := glyphAdvance(r) * h
+		// The glyph is centered in its advance, with some space on both
+		// sides.
+		width := advance - 0.15*h
+		for _, stroke := range glyphs[r] {
+			points := make([]vec, len(stroke))
+			for i, q := range stroke {
+				points[i] = vec{left + (advance-width)/2 + q.x*width, top + q.y*size}
+			}
+			polygons = append(polygons, thickPolyline(points, h/11)...)
+		}
+		left += advance
+	}
+	p.fill(polygons, color)
+}
+
+func (p *imagePainter) TextRectFormat(x, y, w, h int, s string, align textAlignment, color color.RGBA) {
+	alignText(p, int(p.fontHeight()), x, y, w, h, s, align, color)
+}
+
+// alignText draws lines of text, separated by \n, aligned in the rectangle
+// like wui.Canvas.TextRectFormat does. Unlike on the canvas, long lines are
+// not wrapped.
+func alignText(p painter, lineHeight, x, y, w, h int, s string, align textAlignment, color color.RGBA) {
+	if s == "" {
+		return
+	}
+	lines := strings.Split(s, "\n")
+	top := y
+	if total := len(lines) * lineHeight; align == alignCenter && total < h {
+		top += (h - total) / 2
+	}
+	for i, line := range lines {
+		lineWidth, _ := p.TextExtent(line)
+		left := x
+		if align == alignCenter {
+			left += (w - lineWidth) / 2
+		}
+		p.TextOut(left, top+i*lineHeight, line, color)
+	}
+}
+
+// vec is a point with sub-pixel precision.
+type vec struct {
+	x, y float64
+}
+
+// fill fills the union of the polygons, each with the even-odd rule like on
+// the canvas. The edges are anti-aliased by sampling several lines per pixel
+// row and using the exact horizontal coverage of the pixels.
+func (p *imagePainter) fill(polygons [][]vec, c color.RGBA) {
+	bounds := p.img.Bounds()
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, polygon := range polygons {
+		for _, q := range polygon {
+			minX, minY = math.Min(minX, q.x), math.Min(minY, q.y)
+			maxX, maxY = math.Max(maxX, q.x), math.Max(maxY, q.y)
+		}
+	}
+	left := clamp(int(math.Floor(minX)), bounds.Min.X, bounds.Max.X)
+	right := clamp(int(math.Ceil(maxX)), bounds.Min.X, bounds.Max.X)
+	top := clamp(int(math.Floor(minY)), bounds.Min.Y, bounds.Max.Y)
+	bottom := clamp(int(math.Ceil(maxY)), bounds.Min.Y, bounds.Max.Y)
+	if left >= right || top >= bottom {
+		return
+	}
+
+	const samples = 4
+	coverage := make([]float64, right-left)
+	var spans [][2]float64
+	var crossings []float64
+	for py := top; py < bottom; py++ {
+		for i := range coverage {
+			coverage[i] = 0
+		}
+		for s := 0; s < samples; s++ {
+			y := float64(py) + (float64(s)+0.5)/samples
+
+			// Collect the inside spans of all polygons on this line, then
+			// merge overlapping spans so they are not covered twice.
+			spans = spans[:0]
+			for _, polygon := range polygons {
+				crossings = crossings[:0]
+				for i := range polygon {
+					a, b := polygon[i], polygon[(i+1)%len(polygon)]
+					if (a.y <= y) != (b.y <= y) {
+						crossings = append(crossings, a.x+(y-a.y)*(b.x-a.x)/(b.y-a.y))
+					}
+				}
+				sort.Float64s(crossings)
+				for k := 0; k+1 < len(crossings); k += 2 {
+					spans = append(spans, [2]float64{crossings[k], crossings[k+1]})
+				}
+			}
+			sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+			for k := 0; k < len(spans); {
+				from, to := spans[k][0], spans[k][1]
+				for k++; k < len(spans) && spans[k][0] <= to; k++ {
+					to = math.Max(to, spans[k][1])
+				}
+				from = math.Max(from, float64(left))
+				to = math.Min(to, float64(right))
+				for px := int(math.Floor(from)); float64(px) < to; px++ {
+					coverage[px-left] += math.Min(to, float64(px+1)) - math.Max(from, float64(px))
+				}
+			}
+		}
+
+		for i, cover := range coverage {
+			if cover <= 0 {
+				continue
+			}
+			alpha := math.Min(cover/samples, 1)
+			offset := p.img.PixOffset(left+i, py)
+			pix := p.img.Pix[offset : offset+4]
+			for k, v := range []uint8{c.R, c.G, c.B} {
+				pix[k] = uint8(math.Round(float64(pix[k])*(1-alpha) + float64(v)*alpha))
+			}
+			pix[3] = 255
+		}
+	}
+}
+
+func clamp(x, min, max int) int {
+	if x < min {
+		return min
+	}
+	if x > max {
+		return max
+	}
+	return x
+}
+
+// ellipse returns a polygon close to the ellipse around the center with the
+// radii rx and ry.
+func ellipse(cx, cy, rx, ry float64) []vec {
+	n := int(math.Max(16, math.Ceil(math.Max(rx, ry))))
+	points := make([]vec, n)
+	for i := range points {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		points[i] = vec{cx + rx*math.Cos(a), cy + ry*math.Sin(a)}
+	}
+	return points
+}
+
+// thickPolyline returns polygons which together cover a line of the given
+// width through the points, with round joints and ends.
+func thickPolyline(points []vec, width float64) [][]vec {
+	r := width / 2
+	var polygons [][]vec
+	for i, p := range points {
+		polygons = append(polygons, ellipse(p.x, p.y, r, r))
+		if i == 0 {
+			continue
+		}
+		q := points[i-1]
+		dx, dy := p.x-q.x, p.y-q.y
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		nx, ny := -dy/length*r, dx/length*r
+		polygons = append(polygons, []vec{
+			{q.x + nx, q.y + ny},
+			{p.x + nx, p.y + ny},
+			{p.x - nx, p.y - ny},
+			{q.x - nx, q.y - ny},
+		})
+	}
+	return polygons
+}
+
+// textExtent is the size of the text in the stroke font with the given line
+// height.
+func textExtent(height float64, s string) (int, int) {
+	w := 0.0
+	for _, r := range s {
+		w += glyphAdvance(r) * height
+	}
+	return int(math.Ceil(w)), int(height)
+}
+
+// glyphAdvance is the width of a character relative to the font height.
+func glyphAdvance(r rune) float64 {
+	if '0' <= r && r <= '9' {
+		return 0.55
+	}
+	return 0.64
+}
+
+// glyphs are the strokes of the characters of the stroke font, in a box of
+// size 1 where y goes down from the top of the capital letters to the
+// baseline.
+var glyphs = func() map[rune][][]vec {
+	// arc returns points on an ellipse, from and to are angles in degrees,
+	// going clockwise from the right.
+	arc := func(cx, cy, rx, ry, from, to float64) []vec {
+		var points []vec
+		steps := int(math.Ceil(math.Abs(to-from) / 10))
+		for i := 0; i <= steps; i++ {
+			a := (from + (to-from)*float64(i)/float64(steps)) * math.Pi / 180
+			points = append(points, vec{cx + rx*math.Cos(a), cy + ry*math.Sin(a)})
+		}
+		return points
+	}
+	join := func(parts ...[]vec) []vec {
+		var points []vec
+		for _, part := range parts {
+			points = append(points, part...)
+		}
+		return points
+	}
+	six := [][]vec{
+		arc(0.5, 0.7, 0.45, 0.3, 0, 360),
+		arc(0.62, 0.6, 0.57, 0.6, 180, 285),
+	}
+	nine := make([][]vec, len(six))
+	for i, stroke := range six {
+		for _, q := range stroke {
+			nine[i] = append(nine[i], vec{1 - q.x, 1 - q.y})
+		}
+	}
+	return map[rune][][]vec{
+		'0': {arc(0.5, 0.5, 0.5, 0.5, 0, 360)},
+		'1': {{{0.2, 0.2}, {0.55, 0}, {0.55, 1}}, {{0.2, 1}, {0.9, 1}}},
+		'2': {join(arc(0.5, 0.28, 0.45, 0.28, 200, 390), []vec{{0, 1}, {1, 1}})},
+		'3': {arc(0.5, 0.25, 0.42, 0.25, 200, 450), arc(0.5, 0.74, 0.47, 0.24, 270, 520)},
+		'4': {{{0.75, 1}, {0.75, 0}, {0, 0.7}, {1, 0.7}}},
+		'5': {join([]vec{{0.9, 0}, {0.15, 0}, {0.1, 0.45}}, arc(0.5, 0.68, 0.45, 0.32, 230, 510))},
+		'6': six,
+		'7': {{{0, 0}, {1, 0}, {0.35, 1}}},
+		'8': {arc(0.5, 0.25, 0.4, 0.25, 0, 360), arc(0.5, 0.74, 0.47, 0.26, 0, 360)},
+		'9': nine,
+		'A': {{{0, 1}, {0.5, 0}, {1, 1}}, {{0.2, 0.62}, {0.8, 0.62}}},
+		'B': {
+			{{0.1, 0}, {0.1, 1}},
+			join([]vec{{0.1, 0}}, arc(0.6, 0.25, 0.3, 0.25, 270, 450), []vec{{0.1, 0.5}}),
+			join(arc(0.62, 0.75, 0.33, 0.25, 270, 450), []vec{{0.1, 1}}),
+		},
+		'C': {arc(0.55, 0.5, 0.5, 0.5, 40, 320)},
+		'D': {{{0.1, 0}, {0.1, 1}}, join([]vec{{0.1, 0}}, arc(0.45, 0.5, 0.5, 0.5, 270, 450), []vec{{0.1, 1}})},
+		'E': {{{0.9, 0}, {0.1, 0}, {0.1, 1}, {0.9, 1}}, {{0.1, 0.5}, {0.8, 0.5}}},
+		'F': {{{0.9, 0}, {0.1, 0}, {0.1, 1}}, {{0.1, 0.5}, {0.8, 0.5}}},
+		'G': {arc(0.5, 0.5, 0.5, 0.5, 10, 320), {{0.55, 0.55}, {1, 0.55}, {1, 0.8}}},
+		'X': {{{0, 0}, {1, 1}}, {{1, 0}, {0, 1}}},
+		'V': {{{0, 0}, {0.5, 1}, {1, 0}}},
+	}
+}()
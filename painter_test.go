@@ -0,0 +1,76 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestImagePainterDrawsBoard(t *testing.T) {
+	givens := newGrid(9)
+	givens[0] = 5
+	b := givensBoard(givens)
+	b[4][4].number = 7
+
+	for _, size := range []int{300, 1200} {
+		layoutImage(size)
+		img := newImagePainter(size, size)
+		img.FillRect(0, 0, size, size, backColor)
+		paintBoard(img, b, classicRules(), nil, false)
+
+		// count returns how many pixels of the tile in the given column and
+		// row have the color.
+		count := func(col, row int, c color.RGBA) int {
+			x, y := tileTopLeft(col, row)
+			n := 0
+			for dy := 0; dy < tileSize; dy++ {
+				for dx := 0; dx < tileSize; dx++ {
+					if img.img.RGBAAt(x+dx, y+dy) == c {
+						n++
+					}
+				}
+			}
+			return n
+		}
+		if n := count(0, 0, fixedColor); n < tileSize {
+			t.Errorf("%d: given digit has %d pixels in its color", size, n)
+		}
+		if n := count(0, 0, textColor); n != 0 {
+			t.Errorf("%d: given digit has %d pixels in the color of entered digits", size, n)
+		}
+		if n := count(4, 4, textColor); n < tileSize {
+			t.Errorf("%d: entered digit has %d pixels in its color", size, n)
+		}
+		if n := count(4, 4, fixedColor); n != 0 {
+			t.Errorf("%d: entered digit has %d pixels in the color of givens", size, n)
+		}
+		if n := count(8, 8, backColor); n != tileSize*tileSize {
+			t.Errorf("%d: empty tile has %d of %d pixels in the background color", size, n, tileSize*tileSize)
+		}
+
+		// The gaps between the tiles are the borders, thick ones around the
+		// boxes and thin ones inside them. The middle row of tiles is between
+		// the thick borders of the middle box.
+		_, y := tileTopLeft(0, 4)
+		y += tileSize / 2
+		left := boardX
+		for col := 0; col <= 9; col++ {
+			right := boardX + boardSize
+			if col < 9 {
+				right, _ = tileTopLeft(col, 4)
+			}
+			want := thinBorderSize
+			if col%3 == 0 {
+				want = thickBorderSize
+			}
+			if right-left != want {
+				t.Errorf("%d: border before column %d is %d pixels wide, want %d", size, col, right-left, want)
+			}
+			for x := left; x < right; x++ {
+				if c := img.img.RGBAAt(x, y); c != borderColor {
+					t.Errorf("%d: border pixel %d,%d is %v", size, x, y, c)
+				}
+			}
+			left = right + tileSize
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+// parity is what a marker in a cell says about its digit.
+type parity int
+
+const (
+	noParity parity = iota
+	even
+	odd
+)
+
+// fits returns true if digit n may go into a cell with the marker.
+func (p parity) fits(n int) bool {
+	switch p {
+	case even:
+		return n%2 == 0
+	case odd:
+		return n%2 == 1
+	}
+	return true
+}
+
+// parityConstraint holds the markers of an even/odd sudoku. Even cells are
+// marked with a square, odd cells with a circle.
+type parityConstraint struct {
+	markers [81]parity
+}
+
+func (c parityConstraint) houses() [][]int {
+	return nil
+}
+
+func (c parityConstraint) allows(g grid, i, n int) bool {
+	return c.markers[i].fits(n)
+}
+
+// conflicts returns the marked cells whose digit does not fit the marker.
+func (c parityConstraint) conflicts(g grid) [][]int {
+	var conflicts [][]int
+	for i, p := range c.markers {
+		if g[i] != 0 && !p.fits(g[i]) {
+			conflicts = append(conflicts, []int{i})
+		}
+	}
+	return conflicts
+}
+
+func (c parityConstraint) hints() []hint {
+	var hints []hint
+	for i, p := range c.markers {
+		switch p {
+		case even:
+			hints = append(hints, hint{kind: evenHint, cells: []int{i}})
+		case odd:
+			hints = append(hints, hint{kind: oddHint, cells: []int{i}})
+		}
+	}
+	return hints
+}
+
+// symmetricUnder returns true if t moves every marker onto the same marker
+// and keeps the parity of the digits.
+func (c parityConstraint) symmetricUnder(t transform) bool {
+	for n := 1; n <= 9; n++ {
+		if t.digits[n]%2 != n%2 {
+			return false
+		}
+	}
+	for i, p := range c.markers {
+		col, row := t.target(i%9, i/9)
+		if c.markers[col+9*row] != p {
+			return false
+		}
+	}
+	return true
+}
+
+// newParityGame generates an even/odd sudoku. Every cell of a random solution
+// is marked at first, then givens are removed as long as the game stays unique
+// and after that as many markers as possible.
+func newParityGame(extra rules, givenDigits int) (rules, grid, error) {
+	r := append(classicRules(), extra...)
+	solution, err := r.randomSolution()
+	if err != nil {
+		return r, solution, err
+	}
+
+	var c parityConstraint
+	for i, n := range solution {
+		c.markers[i] = odd
+		if n%2 == 0 {
+			c.markers[i] = even
+		}
+	}
+	start := removeGivens(append(r, c), solution, givenDigits)
+
+	// Every cell's marker is a clue.
+	kept := func(dropped []bool) parityConstraint {
+		fewer := c
+		for i := range dropped {
+			if dropped[i] {
+				fewer.markers[i] = noParity
+			}
+		}
+		return fewer
+	}
+	dropped := removeClues(start, len(c.markers), func(dropped []bool) rules {
+		return append(r, kept(dropped))
+	})
+	return append(r, kept(dropped)), start, nil
+}
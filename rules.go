@@ -0,0 +1,463 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// constraint is one rule of a sudoku variant. The classic rules are made of
+// three constraints: rows, columns and boxes. Variants add their own
+// constraints or replace the classic ones.
+//
+// Cells are numbered like in a grid, digits are 1..n for a grid of size n and
+// 0 means the cell is empty.
+type constraint interface {
+	// houses returns groups of n cells which must contain every digit exactly
+	// once. The solver handles these efficiently as exact cover columns.
+	houses() [][]int
+	// allows returns false if digit n cannot go into the empty cell i, given
+	// the digits placed in g so far. It is used to eliminate candidates while
+	// solving. Constraints which are fully described by their houses always
+	// return true.
+	allows(g grid, i, n int) bool
+	// conflicts returns groups of cells whose digits break the constraint.
+	// Empty cells never conflict.
+	conflicts(g grid) [][]int
+	// hints tells the renderer what to draw on the board for the constraint.
+	hints() []hint
+}
+
+// hint is something that a constraint wants to be drawn on the board.
+type hint struct {
+	kind  hintKind
+	cells []int
+	text  string
+}
+
+type hintKind int
+
+const (
+	// shadeHint shades the background of its cells.
+	shadeHint hintKind = iota
+	// lineHint is a line connecting the centers of its cells, in order.
+	lineHint
+	// regionHint is one of the regions which replace the boxes. The board is
+	// drawn with thick borders between the regions instead of the boxes.
+	regionHint
+	// thermoHint is a thermometer, a bulb in its first cell and a thick line
+	// through the centers of the others.
+	thermoHint
+	// arrowHint is an arrow with its circle in the first cell, going
+	// through the centers of the other cells.
+	arrowHint
+	// cageHint is a dashed outline around its cells with the text in the top
+	// left corner of the first cell in reading order.
+	cageHint
+	// clueHint is a clue outside the grid. Its text is written one cell
+	// before the first cell, in the direction away from the second one.
+	clueHint
+	// littleKillerHint is a clue like clueHint with a small arrow pointing
+	// along the diagonal of its cells.
+	littleKillerHint
+	// whiteDotHint is a white dot on the border between its two cells.
+	whiteDotHint
+	// blackDotHint is a black dot on the border between its two cells.
+	blackDotHint
+	// edgeTextHint is its text written on the border between its two cells.
+	edgeTextHint
+	// greaterHint is a greater-than sign on the border between its two
+	// cells, opening towards the first one, which has the larger digit.
+	greaterHint
+	// evenHint and oddHint mark cells which must have an even or odd digit.
+	evenHint
+	oddHint
+	// whisperHint, renbanHint and palindromeHint are lines through the
+	// centers of their cells, in the color of their kind.
+	whisperHint
+	renbanHint
+	palindromeHint
+)
+
+// rules are all the constraints of a sudoku variant.
+type rules []constraint
+
+// classicRules are the rules of the 9x9 sudoku.
+func classicRules() rules {
+	return shapeRules(classicShape)
+}
+
+// shapeRules are the rules of a sudoku of the given shape: every row, column
+// and box must contain every digit.
+func shapeRules(s shape) rules {
+	if s.samurai {
+		return samuraiRules()
+	}
+	n := s.size
+	boxesPerRow := n / s.boxWidth
+	var rows, cols, boxes [][]int
+	for i := 0; i < n; i++ {
+		var row, col, box []int
+		// The top-left cell of box i.
+		left, top := s.boxWidth*(i%boxesPerRow), s.boxHeight*(i/boxesPerRow)
+		for j := 0; j < n; j++ {
+			row = append(row, n*i+j)
+			col = append(col, i+n*j)
+			box = append(box, left+j%s.boxWidth+n*(top+j/s.boxWidth))
+		}
+		rows = append(rows, row)
+		cols = append(cols, col)
+		boxes = append(boxes, box)
+	}
+	return rules{
+		houseConstraint{groups: rows},
+		houseConstraint{groups: cols},
+		houseConstraint{groups: boxes},
+	}
+}
+
+// houseConstraint is a list of houses, each must contain every digit once.
+type houseConstraint struct {
+	groups [][]int
+	// drawing is what the renderer shows for the houses. The classic houses
+	// need nothing because the board's layout shows them.
+	drawing []hint
+}
+
+func (c houseConstraint) houses() [][]int {
+	return c.groups
+}
+
+func (c houseConstraint) allows(grid, int, int) bool {
+	return true
+}
+
+func (c houseConstraint) conflicts(g grid) [][]int {
+	var conflicts [][]int
+	for _, house := range c.groups {
+		conflicts = append(conflicts, repeatedDigits(g, house)...)
+	}
+	return conflicts
+}
+
+func (c houseConstraint) hints() []hint {
+	return c.drawing
+}
+
+// housesSymmetricUnder returns true if t maps the given houses of a grid of
+// the given size onto themselves. The order of the houses and of the cells in
+// them does not matter. Transforms only exist for 9x9 grids, houses of other
+// grids are never symmetric.
+func housesSymmetricUnder(houses [][]int, size int, t transform) bool {
+	if size != 9 {
+		return false
+	}
+	have := make(map[string]bool)
+	for _, house := range houses {
+		have[cellSetKey(house)] = true
+	}
+	for _, house := range houses {
+		moved := make([]int, len(house))
+		for j, i := range house {
+			col, row := t.target(i%size, i/size)
+			moved[j] = col + size*row
+		}
+		if !have[cellSetKey(moved)] {
+			return false
+		}
+	}
+	return true
+}
+
+// cellSetKey returns a string which is the same for all orders of the given
+// cells.
+func cellSetKey(cells []int) string {
+	sorted := append([]int(nil), cells...)
+	sort.Ints(sorted)
+	return fmt.Sprint(sorted)
+}
+
+// repeatedDigits returns the pairs of cells in the given list which contain
+// the same digit.
+func repeatedDigits(g grid, cells []int) [][]int {
+	var pairs [][]int
+	for a := range cells {
+		for b := a + 1; b < len(cells); b++ {
+			i, j := cells[a], cells[b]
+			if g[i] != 0 && g[i] == g[j] {
+				pairs = append(pairs, []int{i, j})
+			}
+		}
+	}
+	return pairs
+}
+
+// conflicts returns all cells whose digits break any of the rules.
+func (r rules) conflicts(g grid) []bool {
+	cells := make([]bool, len(g))
+	for _, c := range r {
+		for _, group := range c.conflicts(g) {
+			for _, i := range group {
+				cells[i] = true
+			}
+		}
+	}
+	return cells
+}
+
+// distinct is implemented by constraints which forbid repeated digits within
+// groups of cells. The solver handles these groups efficiently as exact cover
+// columns which may be covered at most once, in addition to what allows rules
+// out.
+type distinct interface {
+	distinctGroups() [][]int
+}
+
+// symmetric is implemented by constraints which can tell whether a transform
+// leaves them unchanged.
+type symmetric interface {
+	symmetricUnder(t transform) bool
+}
+
+// symmetricUnder returns true if t turns every game of these rules into a game
+// of the same rules. The houses of all house constraints are considered
+// together, e.g. rotating the board turns rows into columns. Digits do not
+// matter for houses. Other constraints that do not implement symmetric are
+// not considered symmetric at all. Rules for grids other than 9x9 are never
+// symmetric, there are no transforms for them.
+func (r rules) symmetricUnder(t transform) bool {
+	if r.cells() != 81 {
+		return false
+	}
+	var houses [][]int
+	for _, c := range r {
+		if h, ok := c.(houseConstraint); ok {
+			houses = append(houses, h.groups...)
+			continue
+		}
+		s, ok := c.(symmetric)
+		if !ok || !s.symmetricUnder(t) {
+			return false
+		}
+	}
+	return housesSymmetricUnder(houses, r.size(), t)
+}
+
+// symmetricUnderAll returns true if every transform turns games of these rules
+// into games of the same rules. Only for these rules, two games are the same
+// if their givens are equivalent, see equivalentGames.
+func (r rules) symmetricUnderAll() bool {
+	for _, t := range elementaryTransforms() {
+		if !r.symmetricUnder(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// size is the number of digits in the rules, which is the number of cells in
+// a house. Rules always have the houses of their shape.
+func (r rules) size() int {
+	for _, c := range r {
+		if houses := c.houses(); len(houses) > 0 {
+			return len(houses[0])
+		}
+	}
+	panic("rules without houses have no size")
+}
+
+// cells is the number of cells in a grid for the rules. Cells after the last
+// cell of any house, or between them, are holes which are never filled.
+func (r rules) cells() int {
+	cells := 0
+	for _, c := range r {
+		for _, house := range c.houses() {
+			for _, i := range house {
+				if i >= cells {
+					cells = i + 1
+				}
+			}
+		}
+	}
+	if cells == 0 {
+		panic("rules without houses have no cells")
+	}
+	return cells
+}
+
+// hints returns the hints of all constraints.
+func (r rules) hints() []hint {
+	var hints []hint
+	for _, c := range r {
+		hints = append(hints, c.hints()...)
+	}
+	return hints
+}
+
+// hasHint returns true if any constraint has a hint of the given kind.
+func (r rules) hasHint(kind hintKind) bool {
+	for _, h := range r.hints() {
+		if h.kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleCover is the exact cover matrix for a set of rules. Every cell must hold
+// one digit and every house must contain every digit.
+type ruleCover struct {
+	*exactCover
+	// size is the number of digits.
+	size int
+	// cells is the number of cells, including holes.
+	cells int
+	// placements maps the matrix rows to size*i+n-1 for digit n in cell i.
+	placements []int
+	// rowOf is the inverse of placements. It is -1 for the digits of holes,
+	// which are not in the matrix.
+	rowOf []int
+	// pruners are the constraints which cannot be fully described by exact
+	// cover columns.
+	pruners []constraint
+}
+
+// newCover creates the exact cover matrix for the rules. If shuffle is true,
+// the rows are added in random order which makes the solver find a random
+// solution first.
+func (r rules) newCover(shuffle bool) *ruleCover {
+	var houses, groups [][]int
+	var pruners []constraint
+	for _, c := range r {
+		houses = append(houses, c.houses()...)
+		if d, ok := c.(distinct); ok {
+			groups = append(groups, d.distinctGroups()...)
+		}
+		switch c.(type) {
+		case houseConstraint, chessConstraint:
+			// These are fully described by their columns.
+		default:
+			pruners = append(pruners, c)
+		}
+	}
+
+	// houseOf[i] lists the houses that cell i is part of, followed by the
+	// groups of distinct digits, which are numbered after the houses.
+	size := r.size()
+	cells := r.cells()
+	houseOf := make([][]int, cells)
+	for h, house := range append(houses, groups...) {
+		for _, i := range house {
+			houseOf[i] = append(houseOf[i], h)
+		}
+	}
+
+	// Cells which are in no house are holes. They have no column and no
+	// rows, the other cells are numbered in cellColumn.
+	cellColumn := make([]int, cells)
+	holes := 0
+	for i := range cellColumn {
+		cellColumn[i] = i - holes
+		if len(houseOf[i]) == 0 || houseOf[i][0] >= len(houses) {
+			cellColumn[i] = -1
+			holes++
+		}
+	}
+	used := cells - holes
+
+	m := &ruleCover{
+		exactCover: newExactCover(used+size*len(houses), size*len(groups)),
+		size:       size,
+		cells:      cells,
+		pruners:    pruners,
+	}
+	m.rowOf = make([]int, cells*size)
+	for p := range m.rowOf {
+		m.rowOf[p] = -1
+		if cellColumn[p/size] != -1 {
+			m.placements = append(m.placements, p)
+		}
+	}
+	if shuffle {
+		rand.Shuffle(len(m.placements), func(i, j int) {
+			m.placements[i], m.placements[j] = m.placements[j], m.placements[i]
+		})
+	}
+
+	var columns []int
+	for row, p := range m.placements {
+		i, n := p/size, p%size
+		columns = append(columns[:0], cellColumn[i])
+		for _, h := range houseOf[i] {
+			columns = append(columns, used+size*h+n)
+		}
+		m.addRow(columns...)
+		m.rowOf[p] = row
+	}
+
+	if len(pruners) > 0 {
+		m.prune = func() []int {
+			// Drop the candidates that the other constraints rule out, given
+			// the digits placed so far.
+			g := m.game(m.solution)
+			var rejected []int
+			for row, p := range m.placements {
+				if m.available(row) && !m.allows(g, p/size, p%size+1) {
+					rejected = append(rejected, row)
+				}
+			}
+			return rejected
+		}
+	}
+
+	return m
+}
+
+// place puts the givens of g into every solution. It returns false if g is
+// invalid.
+func (m *ruleCover) place(g grid) bool {
+	if len(g) != m.cells {
+		return false
+	}
+	for _, n := range g {
+		if n < 0 || n > m.size {
+			return false
+		}
+	}
+	h := g.clone()
+	for i, n := range g {
+		if n != 0 {
+			// The houses are checked by the matrix, the other constraints
+			// have to be asked.
+			h[i] = 0
+			row := m.rowOf[m.size*i+n-1]
+			ok := row != -1 && m.allows(h, i, n) && m.selectRow(row)
+			h[i] = n
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// allows returns true if all constraints which are not houses allow digit n in
+// cell i of g.
+func (m *ruleCover) allows(g grid, i, n int) bool {
+	for _, c := range m.pruners {
+		if !c.allows(g, i, n) {
+			return false
+		}
+	}
+	return true
+}
+
+// game returns the game with the digits placed by the given rows.
+func (m *ruleCover) game(rows []int) grid {
+	g := make(grid, m.cells)
+	for _, r := range rows {
+		p := m.placements[r]
+		g[p/m.size] = p%m.size + 1
+	}
+	return g
+}
@@ -0,0 +1,58 @@
+package main
+
+import "errors"
+
+// samuraiGrids are the top-left cells of the five 9x9 grids of a samurai
+// sudoku, in columns and rows of the 21x21 board. The grid in the middle
+// shares its corner boxes with the other four.
+var samuraiGrids = [][2]int{{0, 0}, {12, 0}, {6, 6}, {0, 12}, {12, 12}}
+
+// samuraiRules are the classic rules for each of the five grids. The shared
+// boxes are only listed once.
+func samuraiRules() rules {
+	n := samuraiShape.size
+	// The rows, columns and boxes of all grids go into the constraint for
+	// rows, columns and boxes.
+	classic := classicRules()
+	houses := make([][][]int, len(classic))
+	have := make(map[string]bool)
+	for _, g := range samuraiGrids {
+		offset := g[0] + n*g[1]
+		for k, c := range classic {
+			for _, house := range c.houses() {
+				moved := make([]int, len(house))
+				for j, i := range house {
+					moved[j] = offset + i%9 + n*(i/9)
+				}
+				if key := cellSetKey(moved); !have[key] {
+					have[key] = true
+					houses[k] = append(houses[k], moved)
+				}
+			}
+		}
+	}
+	var r rules
+	for _, groups := range houses {
+		r = append(r, houseConstraint{groups: groups})
+	}
+	return r
+}
+
+// newSamuraiGame creates a samurai game. The grids are always 9x9 so s must be
+// the classic shape. The extra rules are made for a 9x9 grid, chess rules
+// apply across the whole board instead.
+func newSamuraiGame(s shape, extra rules, givenDigits int) (rules, grid, error) {
+	if s != classicShape {
+		return nil, nil, errors.New("samurai is made of 9x9 grids")
+	}
+	r := samuraiRules()
+	for _, c := range extra {
+		if chess, ok := c.(chessConstraint); ok {
+			chess.size = samuraiShape.size
+			c = chess
+		}
+		r = append(r, c)
+	}
+	g, err := generateNewGame(r, givenDigits)
+	return r, g, err
+}
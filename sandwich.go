@@ -0,0 +1,187 @@
+package main
+
+// sandwichConstraint holds the clues of a sandwich sudoku. A clue is the sum
+// of the digits between the 1 and the 9 in its row or column.
+type sandwichConstraint struct {
+	clues []lineClue
+	// cluesAt lists the clues whose line goes through each cell.
+	cluesAt [81][]int
+}
+
+func newSandwichConstraint(clues []lineClue) sandwichConstraint {
+	c := sandwichConstraint{clues: clues}
+	for k, clue := range clues {
+		for _, i := range clue.cells {
+			c.cluesAt[i] = append(c.cluesAt[i], k)
+		}
+	}
+	return c
+}
+
+func (c sandwichConstraint) houses() [][]int {
+	return nil
+}
+
+func (c sandwichConstraint) allows(g grid, i, n int) bool {
+	if len(c.cluesAt[i]) == 0 {
+		return true
+	}
+	// Try n in cell i, without copying the grid.
+	was := g[i]
+	g[i] = n
+	defer func() { g[i] = was }()
+	for _, k := range c.cluesAt[i] {
+		if !sandwichPossible(g, c.clues[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sandwichPossible returns false if the 1 and the 9 cannot be placed in the
+// line so that the digits between them add up to the clue. The digits between
+// them are all different and neither 1 nor 9, and they are not used anywhere
+// else in the line.
+func sandwichPossible(g grid, clue lineClue) bool {
+	line := clue.cells
+	// sum[k] and empty[k] are the sum of the digits and the number of empty
+	// cells in line[:k]. This runs for every candidate while solving so it
+	// does not allocate.
+	var sum, empty [10]int
+	var used [10]bool
+	var ones, nines [9]int
+	oneCount, nineCount := 0, 0
+	for k, i := range line {
+		n := g[i]
+		used[n] = true
+		sum[k+1], empty[k+1] = sum[k]+n, empty[k]
+		if n == 0 {
+			empty[k+1]++
+		}
+		if n == 1 {
+			ones[0], oneCount = k, 1
+		}
+		if n == 9 {
+			nines[0], nineCount = k, 1
+		}
+	}
+	for k, i := range line {
+		if g[i] == 0 {
+			if !used[1] {
+				ones[oneCount] = k
+				oneCount++
+			}
+			if !used[9] {
+				nines[nineCount] = k
+				nineCount++
+			}
+		}
+	}
+
+	// least[k] and most[k] are the smallest and largest sums of k of the
+	// digits 2 to 8 which are still free.
+	var least, most [8]int
+	free := 0
+	for n := 2; n <= 8; n++ {
+		if !used[n] {
+			free++
+			least[free] = least[free-1] + n
+		}
+	}
+	free = 0
+	for n := 8; n >= 2; n-- {
+		if !used[n] {
+			free++
+			most[free] = most[free-1] + n
+		}
+	}
+
+	for _, one := range ones[:oneCount] {
+		for _, nine := range nines[:nineCount] {
+			from, to := one, nine
+			if from > to {
+				from, to = to, from
+			}
+			if from == to {
+				continue
+			}
+			s := sum[to] - sum[from+1]
+			e := empty[to] - empty[from+1]
+			if e <= free && s+least[e] <= clue.value && clue.value <= s+most[e] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sandwichCells returns the cells between the 1 and the 9 of the line. It
+// returns false if they are not both placed.
+func sandwichCells(g grid, line []int) ([]int, bool) {
+	one, nine := -1, -1
+	for k, i := range line {
+		if g[i] == 1 {
+			one = k
+		}
+		if g[i] == 9 {
+			nine = k
+		}
+	}
+	if one == -1 || nine == -1 {
+		return nil, false
+	}
+	if one > nine {
+		one, nine = nine, one
+	}
+	return line[one+1 : nine], true
+}
+
+// conflicts returns the digits of sandwiches which cannot add up to their
+// clue, together with the 1 and the 9.
+func (c sandwichConstraint) conflicts(g grid) [][]int {
+	var conflicts [][]int
+	for _, clue := range c.clues {
+		between, ok := sandwichCells(g, clue.cells)
+		if !ok || sandwichPossible(g, clue) {
+			continue
+		}
+		group := []int{}
+		for _, i := range clue.cells {
+			if g[i] == 1 || g[i] == 9 || indexOf(between, i) != -1 && g[i] != 0 {
+				group = append(group, i)
+			}
+		}
+		conflicts = append(conflicts, group)
+	}
+	return conflicts
+}
+
+func (c sandwichConstraint) hints() []hint {
+	return clueHints(c.clues, clueHint)
+}
+
+// symmetricUnder returns true if t moves the clues onto each other. The 1 and
+// 9 must stay where they are so the digits cannot be relabeled.
+func (c sandwichConstraint) symmetricUnder(t transform) bool {
+	return t.digits == identityTransform().digits && lineCluesSymmetricUnder(c.clues, t, false)
+}
+
+// newSandwichGame generates a sandwich sudoku with clues for the rows and
+// columns, see newLineClueGame.
+func newSandwichGame(extra rules, givenDigits int) (rules, grid, error) {
+	newConstraint := func(clues []lineClue) constraint {
+		return newSandwichConstraint(clues)
+	}
+	return newLineClueGame(sideLines(), sandwichSum, newConstraint, extra, givenDigits)
+}
+
+// sandwichSum returns the sum of the digits between the 1 and the 9 of a full
+// line.
+func sandwichSum(solution grid, line []int) int {
+	between, _ := sandwichCells(solution, line)
+	sum := 0
+	for _, i := range between {
+		sum += solution[i]
+	}
+	return sum
+}
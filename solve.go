@@ -0,0 +1,121 @@
+package main
+
+import "errors"
+
+// countSolutions returns the number of solutions of g but stops counting once
+// limit is reached. Use a limit of 2 to tell unique from ambiguous games. An
+// invalid game (digits out of range or conflicting givens) has 0 solutions.
+func (r rules) countSolutions(g grid, limit int) int {
+	n := 0
+	r.enumerateSolutions(g, func(grid) bool {
+		n++
+		return n < limit
+	})
+	return n
+}
+
+// enumerateSolutions calls f with one solution of g after the other, until
+// either there are no more solutions or f returns false. Solutions are only
+// searched for as they are needed so this is cheap even for games with a huge
+// number of solutions.
+func (r rules) enumerateSolutions(g grid, f func(solution grid) bool) {
+	r.search(g, false, 0, f)
+}
+
+// uniqueWithin returns true if g has exactly one solution. It gives up and
+// returns false if this cannot be decided within the given budget of
+// placements.
+func (r rules) uniqueWithin(g grid, budget int) bool {
+	unique, _ := r.decideUnique(g, budget)
+	return unique
+}
+
+// decideUnique is like uniqueWithin but also tells whether the question was
+// decided within the budget. A second solution decides it, even if the budget
+// runs out afterwards.
+func (r rules) decideUnique(g grid, budget int) (unique, decided bool) {
+	n := 0
+	complete := r.search(g, false, budget, func(grid) bool {
+		n++
+		return n < 2
+	})
+	return complete && n == 1, complete || n == 2
+}
+
+// search calls f with the solutions of g. If shuffle is true, the solutions
+// come in random order. If budget is positive, the search gives up after
+// trying that many placements. It returns false in that case.
+func (r rules) search(g grid, shuffle bool, budget int, f func(solution grid) bool) bool {
+	m := r.newCover(shuffle)
+	m.budget = budget
+	if !m.place(g) {
+		return true
+	}
+	m.search(func(rows []int) bool {
+		return f(m.game(rows))
+	})
+	return budget <= 0 || m.budget > 0
+}
+
+// randomSolution returns a random completely filled grid that satisfies the
+// rules.
+//
+// For some rules the search can get stuck in a hopeless part of the search
+// space for a very long time. It is started over with a new random order
+// after a while and gives up after a number of tries, e.g. when no grid
+// satisfies the rules.
+func (r rules) randomSolution() (grid, error) {
+	for i := 0; i < 200; i++ {
+		if solution, err := r.randomSolutionWithin(10000); err == nil {
+			return solution, nil
+		}
+	}
+	return nil, errors.New("no solution found")
+}
+
+// randomSolutionWithin is like randomSolution but gives up after trying the
+// given number of placements. For some rules it takes very long to find out
+// that there is no solution, or to find one.
+func (r rules) randomSolutionWithin(budget int) (grid, error) {
+	return r.firstSolution(make(grid, r.cells()), true, budget)
+}
+
+func (r rules) firstSolution(g grid, shuffle bool, budget int) (grid, error) {
+	var solution grid
+	found := false
+	r.search(g, shuffle, budget, func(s grid) bool {
+		solution, found = s, true
+		return false
+	})
+	if !found {
+		return g, errors.New("unsolvable game")
+	}
+	return solution, nil
+}
+
+// ambiguousCells returns the indices of the cells in which two different
+// solutions of g differ. These are the cells where a setter would add a clue
+// to make the game unique. If g has less than two solutions, ambiguousCells
+// returns nil.
+func (r rules) ambiguousCells(g grid) []int {
+	var solutions []grid
+	r.enumerateSolutions(g, func(s grid) bool {
+		solutions = append(solutions, s)
+		return len(solutions) < 2
+	})
+	if len(solutions) < 2 {
+		return nil
+	}
+	return differingCells(solutions[0], solutions[1])
+}
+
+// differingCells returns the indices of the cells in which a and b differ.
+func differingCells(a, b grid) []int {
+	var cells []int
+	for i := range a {
+		if a[i] != b[i] {
+			cells = append(cells, i)
+		}
+	}
+	return cells
+}
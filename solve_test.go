@@ -0,0 +1,161 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// A classic game with a unique solution and its solution.
+const (
+	uniqueGame = "53..7....6..195....98....6.8...6...34..8.3..17...2...6.6....28....419..5....8..79"
+	solution   = "534678912672195348198342567859761423426853791713924856961537284287419635345286179"
+)
+
+// parseTestGrid reads the digits of a grid, dots are empty cells and white
+// space is ignored.
+func parseTestGrid(t *testing.T, s string) grid {
+	t.Helper()
+	s = strings.Join(strings.Fields(s), "")
+	g := make(grid, len(s))
+	for i, c := range s {
+		if c != '.' {
+			g[i] = parseDigit(c)
+			if g[i] < 1 {
+				t.Fatalf("invalid digit %q in test grid", c)
+			}
+		}
+	}
+	return g
+}
+
+// ambiguousGame is the solution without its first two rows. The rows can be
+// swapped, so there is more than one solution.
+func ambiguousGame(t *testing.T) grid {
+	g := parseTestGrid(t, solution)
+	for i := 0; i < 18; i++ {
+		g[i] = 0
+	}
+	return g
+}
+
+func TestCountSolutionsStopsAtLimit(t *testing.T) {
+	contradictory := parseTestGrid(t, uniqueGame)
+	contradictory[2] = 5 // There is a 5 in the first row already.
+	outOfRange := parseTestGrid(t, uniqueGame)
+	outOfRange[2] = 10
+
+	tests := []struct {
+		name string
+		game grid
+		want int
+	}{
+		{"unique", parseTestGrid(t, uniqueGame), 1},
+		{"ambiguous", ambiguousGame(t), 2},
+		{"empty", newGrid(9), 2},
+		{"contradictory", contradictory, 0},
+		{"out of range", outOfRange, 0},
+	}
+	for _, tt := range tests {
+		if n := classicRules().countSolutions(tt.game, 2); n != tt.want {
+			t.Errorf("%s: %d solutions, want %d", tt.name, n, tt.want)
+		}
+	}
+}
+
+func TestEnumerateSolutions(t *testing.T) {
+	var solutions []grid
+	classicRules().enumerateSolutions(parseTestGrid(t, uniqueGame), func(s grid) bool {
+		solutions = append(solutions, s)
+		return true
+	})
+	if len(solutions) != 1 || !solutions[0].equals(parseTestGrid(t, solution)) {
+		t.Fatalf("got solutions %v, want only %v", solutions, solution)
+	}
+
+	// Enumeration stops as soon as f returns false, even if there are more
+	// solutions.
+	n := 0
+	classicRules().enumerateSolutions(newGrid(9), func(s grid) bool {
+		n++
+		for i, d := range s {
+			if d == 0 {
+				t.Fatalf("solution has empty cell %d", i)
+			}
+		}
+		return n < 5
+	})
+	if n != 5 {
+		t.Errorf("f was called %d times, want 5", n)
+	}
+
+	// The solutions of the ambiguous game are all different and keep the
+	// givens.
+	game := ambiguousGame(t)
+	solutions = nil
+	classicRules().enumerateSolutions(game, func(s grid) bool {
+		solutions = append(solutions, s.clone())
+		return true
+	})
+	if len(solutions) < 2 {
+		t.Fatalf("got %d solutions, want more than one", len(solutions))
+	}
+	for i := range solutions {
+		for j := i + 1; j < len(solutions); j++ {
+			if solutions[i].equals(solutions[j]) {
+				t.Errorf("solutions %d and %d are the same", i, j)
+			}
+		}
+	}
+	for _, s := range solutions {
+		for i := range game {
+			if game[i] != 0 && s[i] != game[i] {
+				t.Errorf("solution changes given in cell %d", i)
+			}
+		}
+	}
+}
+
+func TestUniqueWithin(t *testing.T) {
+	r := classicRules()
+	game := parseTestGrid(t, uniqueGame)
+	if !r.uniqueWithin(game, 100000) {
+		t.Error("unique game not recognized as unique")
+	}
+	// When the budget runs out, the game does not count as unique.
+	if r.uniqueWithin(game, 1) {
+		t.Error("game counted as unique without enough budget")
+	}
+	if r.uniqueWithin(ambiguousGame(t), 100000) {
+		t.Error("ambiguous game counted as unique")
+	}
+}
+
+func TestDecideUnique(t *testing.T) {
+	r := classicRules()
+	game := parseTestGrid(t, uniqueGame)
+	if unique, decided := r.decideUnique(game, 100000); !unique || !decided {
+		t.Errorf("unique game: got unique %v, decided %v", unique, decided)
+	}
+	if unique, decided := r.decideUnique(game, 1); unique || decided {
+		t.Errorf("too small budget: got unique %v, decided %v", unique, decided)
+	}
+	if unique, decided := r.decideUnique(ambiguousGame(t), 100000); unique || !decided {
+		t.Errorf("ambiguous game: got unique %v, decided %v", unique, decided)
+	}
+}
+
+func TestAmbiguousCells(t *testing.T) {
+	r := classicRules()
+	if cells := r.ambiguousCells(parseTestGrid(t, uniqueGame)); cells != nil {
+		t.Errorf("unique game has ambiguous cells %v", cells)
+	}
+	cells := r.ambiguousCells(ambiguousGame(t))
+	if len(cells) == 0 {
+		t.Fatal("ambiguous game has no ambiguous cells")
+	}
+	for _, i := range cells {
+		if i >= 18 {
+			t.Errorf("cell %d is given but ambiguous", i)
+		}
+	}
+}
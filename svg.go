@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// svgPainter writes what is drawn on it as the elements of an SVG image. Text
+// is written as text elements in the board's font. Their width is estimated
+// with the metrics of the stroke font so each text is anchored at its middle,
+// which keeps centered texts centered in any font.
+type svgPainter struct {
+	width, height int
+	elements      bytes.Buffer
+	font          fontKind
+}
+
+func newSVGPainter(width, height int) *svgPainter {
+	return &svgPainter{width: width, height: height}
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// FillRect writes rectangles with crisp edges so neighboring tiles and
+// borders do not leave seams between them.
+func (p *svgPainter) FillRect(x, y, width, height int, color color.RGBA) {
+	fmt.Fprintf(&p.elements, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" shape-rendering="crispEdges"/>`+"\n",
+		x, y, width, height, svgColor(color))
+}
+
+func (p *svgPainter) FillEllipse(x, y, width, height int, color color.RGBA) {
+	rx, ry := float64(width)/2, float64(height)/2
+	fmt.Fprintf(&p.elements, `<ellipse cx="%g" cy="%g" rx="%g" ry="%g" fill="%s"/>`+"\n",
+		round2(float64(x)+rx), round2(float64(y)+ry), round2(rx), round2(ry), svgColor(color))
+}
+
+func (p *svgPainter) Polygon(points []image.Point, color color.RGBA) {
+	coordinates := make([]string, len(points))
+	for i, q := range points {
+		coordinates[i] = fmt.Sprintf("%d,%d", q.X, q.Y)
+	}
+	fmt.Fprintf(&p.elements, `<polygon points="%s" fill="%s" fill-rule="evenodd"/>`+"\n",
+		strings.Join(coordinates, " "), svgColor(color))
+}
+
+func (p *svgPainter) SetFont(font fontKind) {
+	p.font = font
+}
+
+// fontHeight is the height of a line of text in the current font.
+func (p *svgPainter) fontHeight() float64 {
+	return float64(fontHeight(p.font))
+}
+
+func (p *svgPainter) TextExtent(s string) (width, height int) {
+	return textExtent(p.fontHeight(), s)
+}
+
+// TextOut writes the text with its top-left corner at x and y. Like on the
+// canvas, the font height is the height of the line, the size of the font
+// itself is smaller and the baseline is where its letters end.
+func (p *svgPainter) TextOut(x, y int, s string, color color.RGBA) {
+	h := p.fontHeight()
+	w, _ := p.TextExtent(s)
+	var text bytes.Buffer
+	xml.EscapeText(&text, []byte(s))
+	fmt.Fprintf(&p.elements, `<text x="%g" y="%g" font-family="Tahoma, sans-serif" font-size="%g" text-anchor="middle" fill="%s">%s</text>`+"\n",
+		round2(float64(x)+float64(w)/2), round2(float64(y)+0.83*h), round2(0.83*h), svgColor(color), text.String())
+}
+
+// round2 rounds to two decimals, which is precise enough for any zoom and
+// keeps the file short.
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
+
+func (p *svgPainter) TextRectFormat(x, y, w, h int, s string, align textAlignment, color color.RGBA) {
+	alignText(p, int(p.fontHeight()), x, y, w, h, s, align, color)
+}
+
+// svg returns the SVG document with everything drawn so far.
+func (p *svgPainter) svg() []byte {
+	var doc bytes.Buffer
+	fmt.Fprintf(&doc, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		p.width, p.height, p.width, p.height)
+	doc.Write(p.elements.Bytes())
+	doc.WriteString("</svg>\n")
+	return doc.Bytes()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/xml"
+	"strconv"
+	"testing"
+)
+
+func TestSVGPainterDrawsBoard(t *testing.T) {
+	b := newBoard(9)
+	for i := range b[2][3].corner[:9] {
+		b[2][3].corner[i] = true
+	}
+
+	for _, size := range []int{300, 1200} {
+		layoutImage(size)
+		svg := newSVGPainter(size, size)
+		svg.FillRect(0, 0, size, size, backColor)
+		paintBoard(svg, b, classicRules(), nil, false)
+
+		var doc struct {
+			Rects []struct {
+				X      int    `xml:"x,attr"`
+				Y      int    `xml:"y,attr"`
+				Width  int    `xml:"width,attr"`
+				Height int    `xml:"height,attr"`
+				Fill   string `xml:"fill,attr"`
+			} `xml:"rect"`
+			Texts []struct {
+				X    float64 `xml:"x,attr"`
+				Y    float64 `xml:"y,attr"`
+				Text string  `xml:",chardata"`
+			} `xml:"text"`
+		}
+		if err := xml.Unmarshal(svg.svg(), &doc); err != nil {
+			t.Fatalf("%d: %v", size, err)
+		}
+
+		// The tiles are the rectangles in the background color, the gaps
+		// between them are the borders. The first row of tiles goes across
+		// the board.
+		_, top := tileTopLeft(0, 0)
+		var lefts []int
+		for _, r := range doc.Rects {
+			if r.Fill == svgColor(backColor) && r.Y == top && r.Width == tileSize && r.Height == tileSize {
+				lefts = append(lefts, r.X)
+			}
+		}
+		if len(lefts) != 9 {
+			t.Fatalf("%d: found %d tiles in the first row", size, len(lefts))
+		}
+		for col := 1; col < 9; col++ {
+			want := thinBorderSize
+			if col%3 == 0 {
+				want = thickBorderSize
+			}
+			if gap := lefts[col] - lefts[col-1] - tileSize; gap != want {
+				t.Errorf("%d: border before column %d is %d wide, want %d", size, col, gap, want)
+			}
+		}
+		if gap := lefts[0] - boardX; gap != thickBorderSize {
+			t.Errorf("%d: border around the board is %d wide, want %d", size, gap, thickBorderSize)
+		}
+
+		// The texts are anchored in their middle at their baseline, which
+		// must be inside the bounds of the corner mark.
+		x, y := tileTopLeft(2, 3)
+		marks := 0
+		for _, text := range doc.Texts {
+			n, err := strconv.Atoi(text.Text)
+			if err != nil {
+				t.Errorf("%d: unexpected text %q", size, text.Text)
+				continue
+			}
+			marks++
+			bx, by, bw, bh := cornerPencilMarkBounds(n - 1)
+			if !(float64(x+bx) <= text.X && text.X <= float64(x+bx+bw) &&
+				float64(y+by) <= text.Y && text.Y <= float64(y+by+bh)) {
+				t.Errorf("%d: mark %d at %g,%g is outside of %d,%d %dx%d",
+					size, n, text.X, text.Y, x+bx, y+by, bw, bh)
+			}
+		}
+		if marks != 9 {
+			t.Errorf("%d: found %d corner marks, want 9", size, marks)
+		}
+	}
+}
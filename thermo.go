@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// thermoConstraint holds the thermometers of a thermo sudoku. The digits on a
+// thermometer must strictly increase, starting at its bulb.
+type thermoConstraint struct {
+	// thermos list the cells of each thermometer, the bulb comes first.
+	thermos [][]int
+}
+
+func (c thermoConstraint) houses() [][]int {
+	return nil
+}
+
+func (c thermoConstraint) allows(g grid, i, n int) bool {
+	for _, thermo := range c.thermos {
+		at := indexOf(thermo, i)
+		if at == -1 {
+			continue
+		}
+		// There must be room for the smaller digits below and the larger
+		// digits above n, even where the cells are still empty.
+		if n <= at || n > 9-(len(thermo)-1-at) {
+			return false
+		}
+		for k, j := range thermo {
+			if g[j] == 0 || k == at {
+				continue
+			}
+			if k < at && g[j] > n-(at-k) {
+				return false
+			}
+			if k > at && g[j] < n+(k-at) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// conflicts returns the pairs of digits on a thermometer which do not
+// increase.
+func (c thermoConstraint) conflicts(g grid) [][]int {
+	var pairs [][]int
+	for _, thermo := range c.thermos {
+		for a := range thermo {
+			for b := a + 1; b < len(thermo); b++ {
+				i, j := thermo[a], thermo[b]
+				if g[i] != 0 && g[j] != 0 && g[i] >= g[j] {
+					pairs = append(pairs, []int{i, j})
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+func (c thermoConstraint) hints() []hint {
+	var hints []hint
+	for _, thermo := range c.thermos {
+		hints = append(hints, hint{kind: thermoHint, cells: thermo})
+	}
+	return hints
+}
+
+// symmetricUnder returns true if t moves every thermometer onto another one.
+// Relabeling the digits breaks the order along the thermometers.
+func (c thermoConstraint) symmetricUnder(t transform) bool {
+	if t.digits != identityTransform().digits {
+		return false
+	}
+	have := make(map[string]bool)
+	for _, thermo := range c.thermos {
+		have[strings.Join(cellNames(thermo), " ")] = true
+	}
+	for _, thermo := range c.thermos {
+		moved := make([]int, len(thermo))
+		for j, i := range thermo {
+			col, row := t.target(i%9, i/9)
+			moved[j] = col + 9*row
+		}
+		if !have[strings.Join(cellNames(moved), " ")] {
+			return false
+		}
+	}
+	return true
+}
+
+func indexOf(list []int, x int) int {
+	for i := range list {
+		if list[i] == x {
+			return i
+		}
+	}
+	return -1
+}
+
+// newThermoGame generates a thermo sudoku. The thermometers are laid along
+// increasing digits of a random solution and then givens are removed as long
+// as the game stays unique.
+func newThermoGame(extra rules, givenDigits int) (rules, grid, error) {
+	r := append(classicRules(), extra...)
+	solution, err := r.randomSolution()
+	if err != nil {
+		return r, solution, err
+	}
+	r = append(r, thermoConstraint{thermos: randomThermos(solution)})
+	return r, removeGivens(r, solution, givenDigits), nil
+}
+
+// randomThermos finds thermometers of 3 to 6 cells in the solution. They go
+// from cell to cell in all 8 directions and do not touch cells of other
+// thermometers.
+func randomThermos(solution grid) [][]int {
+	var used [81]bool
+	var thermos [][]int
+	for _, start := range rand.Perm(81) {
+		if len(thermos) == 10 {
+			break
+		}
+		if used[start] || solution[start] > 4 {
+			continue
+		}
+
+		thermo := []int{start}
+		want := 3 + rand.Intn(4)
+		for len(thermo) < want {
+			last := thermo[len(thermo)-1]
+			var next []int
+			for _, j := range kingNeighbors(last) {
+				if !used[j] && indexOf(thermo, j) == -1 && solution[j] > solution[last] {
+					next = append(next, j)
+				}
+			}
+			if len(next) == 0 {
+				break
+			}
+			// Prefer small steps so the thermometer can grow longer.
+			best := next[0]
+			for _, j := range next {
+				if solution[j] < solution[best] || solution[j] == solution[best] && rand.Intn(2) == 0 {
+					best = j
+				}
+			}
+			thermo = append(thermo, best)
+		}
+
+		if len(thermo) >= 3 {
+			for _, i := range thermo {
+				used[i] = true
+			}
+			thermos = append(thermos, thermo)
+		}
+	}
+	return thermos
+}
+
+// kingNeighbors returns the up to 8 cells around cell i.
+func kingNeighbors(i int) []int {
+	var cells []int
+	col, row := i%9, i/9
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			c, r := col+dx, row+dy
+			if (dx != 0 || dy != 0) && 0 <= c && c < 9 && 0 <= r && r < 9 {
+				cells = append(cells, c+9*r)
+			}
+		}
+	}
+	return cells
+}
+
+// cellNames returns the names of the cells in the usual notation, e.g. r1c2
+// is the second cell in the first row.
+func cellNames(cells []int) []string {
+	names := make([]string, len(cells))
+	for k, i := range cells {
+		names[k] = "r" + strconv.Itoa(i/9+1) + "c" + strconv.Itoa(i%9+1)
+	}
+	return names
+}
+
+// parseCellName is the inverse of cellNames for a single cell.
+func parseCellName(name string) (int, error) {
+	name = strings.ToLower(name)
+	if len(name) != 4 || name[0] != 'r' || name[2] != 'c' ||
+		name[1] < '1' || name[1] > '9' || name[3] < '1' || name[3] > '9' {
+		return 0, errors.New("invalid cell " + name)
+	}
+	return int(name[3]-'1') + 9*int(name[1]-'1'), nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import "math/rand"
+
+// transform is a symmetry of the sudoku grid which turns valid games into
+// valid games. The cell in row r and column c of the transformed game is taken
+// from row rows[r] and column cols[c] of the original game, after transposing
+// the original game if transpose is set. Then each digit n is replaced by
+// digits[n].
+//
+// Rows may only be permuted within their band and bands only as a whole, the
+// same goes for columns and stacks. The constructors below make sure of that.
+//
+// Transforms only apply to 9x9 grids.
+type transform struct {
+	transpose bool
+	rows      [9]int
+	cols      [9]int
+	digits    [10]int
+}
+
+func identityTransform() transform {
+	var t transform
+	for i := range t.rows {
+		t.rows[i] = i
+		t.cols[i] = i
+	}
+	for n := range t.digits {
+		t.digits[n] = n
+	}
+	return t
+}
+
+// rotateTransform rotates the grid by 90 degrees clockwise.
+func rotateTransform() transform {
+	t := identityTransform()
+	t.transpose = true
+	for i := range t.rows {
+		t.cols[i] = 8 - i
+	}
+	return t
+}
+
+// mirrorTransform mirrors the grid left to right if horizontal is true,
+// otherwise it mirrors it top to bottom.
+func mirrorTransform(horizontal bool) transform {
+	t := identityTransform()
+	for i := range t.rows {
+		if horizontal {
+			t.cols[i] = 8 - i
+		} else {
+			t.rows[i] = 8 - i
+		}
+	}
+	return t
+}
+
+// transposeTransform mirrors the grid along its main diagonal.
+func transposeTransform() transform {
+	t := identityTransform()
+	t.transpose = true
+	return t
+}
+
+// relabelTransform replaces the digits with a random permutation of them.
+func relabelTransform() transform {
+	t := identityTransform()
+	for i, n := range rand.Perm(9) {
+		t.digits[i+1] = n + 1
+	}
+	return t
+}
+
+// swapBandsTransform swaps the bands a and b (0..2), i.e. three rows at once.
+func swapBandsTransform(a, b int) transform {
+	t := identityTransform()
+	for i := 0; i < 3; i++ {
+		t.rows[3*a+i], t.rows[3*b+i] = t.rows[3*b+i], t.rows[3*a+i]
+	}
+	return t
+}
+
+// swapStacksTransform swaps the stacks a and b (0..2), i.e. three columns at
+// once.
+func swapStacksTransform(a, b int) transform {
+	t := identityTransform()
+	for i := 0; i < 3; i++ {
+		t.cols[3*a+i], t.cols[3*b+i] = t.cols[3*b+i], t.cols[3*a+i]
+	}
+	return t
+}
+
+// swapRowsTransform swaps rows a and b which must lie in the same band.
+func swapRowsTransform(a, b int) transform {
+	t := identityTransform()
+	if a/3 == b/3 {
+		t.rows[a], t.rows[b] = b, a
+	}
+	return t
+}
+
+// swapColsTransform swaps columns a and b which must lie in the same stack.
+func swapColsTransform(a, b int) transform {
+	t := identityTransform()
+	if a/3 == b/3 {
+		t.cols[a], t.cols[b] = b, a
+	}
+	return t
+}
+
+// randomTransform returns one of all possible transforms, each with the same
+// probability.
+func randomTransform() transform {
+	t := relabelTransform()
+	t.transpose = rand.Intn(2) == 0
+	randomLines(&t.rows)
+	randomLines(&t.cols)
+	return t
+}
+
+// randomLines permutes the bands and the lines within each band randomly.
+func randomLines(lines *[9]int) {
+	bands := rand.Perm(3)
+	for b := range bands {
+		for i, line := range rand.Perm(3) {
+			lines[3*b+i] = 3*bands[b] + line
+		}
+	}
+}
+
+// elementaryTransforms returns transforms which, combined, make up all
+// possible transforms.
+func elementaryTransforms() []transform {
+	t := []transform{
+		rotateTransform(),
+		mirrorTransform(true),
+		mirrorTransform(false),
+		transposeTransform(),
+		relabelTransform(),
+	}
+	for a := 0; a < 3; a++ {
+		for b := a + 1; b < 3; b++ {
+			t = append(t, swapBandsTransform(a, b), swapStacksTransform(a, b))
+		}
+	}
+	for a := 0; a < 9; a++ {
+		for b := a + 1; b < 9 && b/3 == a/3; b++ {
+			t = append(t, swapRowsTransform(a, b), swapColsTransform(a, b))
+		}
+	}
+	return t
+}
+
+// disguiseTransform returns a random transform under which the rules are
+// symmetric. For the classic rules these are all transforms, variants usually
+// only allow some of them.
+func disguiseTransform(r rules) transform {
+	t := randomTransform()
+	if r.symmetricUnder(t) {
+		return t
+	}
+
+	// Combine randomly chosen elementary transforms that keep the rules.
+	var allowed []transform
+	for _, t := range elementaryTransforms() {
+		if r.symmetricUnder(t) {
+			allowed = append(allowed, t)
+		}
+	}
+	t = identityTransform()
+	for i := 0; i < 100 && len(allowed) > 0; i++ {
+		t = t.then(allowed[rand.Intn(len(allowed))])
+	}
+	if relabel := relabelTransform(); r.symmetricUnder(relabel) {
+		t = t.then(relabel)
+	}
+	return t
+}
+
+// then returns the transform which first applies t and then u.
+func (t transform) then(u transform) transform {
+	var result transform
+	result.transpose = t.transpose != u.transpose
+	for i := range result.rows {
+		if u.transpose {
+			result.rows[i] = t.cols[u.rows[i]]
+			result.cols[i] = t.rows[u.cols[i]]
+		} else {
+			result.rows[i] = t.rows[u.rows[i]]
+			result.cols[i] = t.cols[u.cols[i]]
+		}
+	}
+	for n := range result.digits {
+		result.digits[n] = u.digits[t.digits[n]]
+	}
+	return result
+}
+
+// source returns the position in the original game which ends up in column
+// col and row row of the transformed game.
+func (t transform) source(col, row int) (srcCol, srcRow int) {
+	srcCol, srcRow = t.cols[col], t.rows[row]
+	if t.transpose {
+		srcCol, srcRow = srcRow, srcCol
+	}
+	return
+}
+
+// target is the inverse of source, it returns where the cell at column col
+// and row row of the original game ends up in the transformed game.
+func (t transform) target(col, row int) (dstCol, dstRow int) {
+	if t.transpose {
+		col, row = row, col
+	}
+	for i := range t.cols {
+		if t.cols[i] == col {
+			dstCol = i
+		}
+		if t.rows[i] == row {
+			dstRow = i
+		}
+	}
+	return
+}
+
+func (t transform) applyGame(g grid) grid {
+	result := newGrid(9)
+	for row := 0; row < 9; row++ {
+		for col := 0; col < 9; col++ {
+			c, r := t.source(col, row)
+			result[col+9*row] = t.digits[g[c+9*r]]
+		}
+	}
+	return result
+}
+
+// applyBoard moves whole fields, including their pencil marks and selection
+// state, and relabels the numbers and pencil marks.
+func (t transform) applyBoard(b board) board {
+	result := newBoard(9)
+	for row := 0; row < 9; row++ {
+		for col := 0; col < 9; col++ {
+			c, r := t.source(col, row)
+			result[col][row] = t.applyField(b[c][r])
+		}
+	}
+	return result
+}
+
+func (t transform) applyField(f field) field {
+	result := f
+	result.number = t.digits[f.number]
+	for i := 0; i < 9; i++ {
+		result.corner[t.digits[i+1]-1] = f.corner[i]
+		result.center[t.digits[i+1]-1] = f.center[i]
+	}
+	return result
+}
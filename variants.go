@@ -0,0 +1,104 @@
+package main
+
+import "errors"
+
+// variant is a kind of sudoku that can be chosen for a new game.
+type variant struct {
+	name string
+	// newGame creates the rules and the givens of a new game of the given
+	// shape with at least givenDigits digits given. The extra constraints are
+	// added to the rules of the variant. It returns an error if no game can
+	// be created for the combined rules.
+	newGame func(s shape, extra rules, givenDigits int) (rules, grid, error)
+}
+
+var variants = []variant{
+	{name: "Classic", newGame: shapedRules(shapeRules)},
+	{name: "Sudoku X", newGame: shapedRules(diagonalRules)},
+	{name: "Killer", newGame: only9x9(newKillerGame)},
+	{name: "Jigsaw", newGame: only9x9(newJigsawGame)},
+	{name: "Windoku", newGame: only9x9(fixedRules(windokuRules))},
+	{name: "Thermo", newGame: only9x9(newThermoGame)},
+	{name: "Arrow", newGame: only9x9(newArrowGame)},
+	{name: "Sandwich", newGame: only9x9(newSandwichGame)},
+	{name: "Little Killer", newGame: only9x9(newOutsideGame(littleKillerClue))},
+	{name: "Skyscraper", newGame: only9x9(newOutsideGame(skyscraperClue))},
+	{name: "X-Sums", newGame: only9x9(newOutsideGame(xSumClue))},
+	{name: "Kropki", newGame: only9x9(newEdgeGame([]edgeKind{whiteDot, blackDot}, false))},
+	{name: "Kropki, All Dots Given", newGame: only9x9(newEdgeGame([]edgeKind{whiteDot, blackDot}, true))},
+	{name: "XV", newGame: only9x9(newEdgeGame([]edgeKind{xClue, vClue}, false))},
+	{name: "XV, All Clues Given", newGame: only9x9(newEdgeGame([]edgeKind{xClue, vClue}, true))},
+	{name: "Whispers, Renban, Palindromes", newGame: only9x9(newLineGame)},
+	{name: "Even/Odd", newGame: only9x9(newParityGame)},
+	{name: "Greater Than", newGame: only9x9(newEdgeGame([]edgeKind{greaterThan, lessThan}, false))},
+	{name: "Samurai", newGame: newSamuraiGame},
+}
+
+// shapedRules creates a newGame function for variants which come in all
+// shapes and whose rules are known before the solution is generated.
+func shapedRules(newRules func(shape) rules) func(shape, rules, int) (rules, grid, error) {
+	return func(s shape, extra rules, givenDigits int) (rules, grid, error) {
+		r := append(newRules(s), extra...)
+		g, err := generateNewGame(r, givenDigits)
+		return r, g, err
+	}
+}
+
+// only9x9 creates a newGame function for variants which only come in 9x9. It
+// returns an error for other shapes.
+func only9x9(newGame func(rules, int) (rules, grid, error)) func(shape, rules, int) (rules, grid, error) {
+	return func(s shape, extra rules, givenDigits int) (rules, grid, error) {
+		if s != classicShape {
+			return nil, nil, errors.New("this variant only comes in 9x9")
+		}
+		return newGame(extra, givenDigits)
+	}
+}
+
+// fixedRules creates a newGame function for 9x9 variants whose rules are known
+// before the solution is generated.
+func fixedRules(newRules func() rules) func(rules, int) (rules, grid, error) {
+	return func(extra rules, givenDigits int) (rules, grid, error) {
+		r := append(newRules(), extra...)
+		g, err := generateNewGame(r, givenDigits)
+		return r, g, err
+	}
+}
+
+// diagonalRules are the rules of Sudoku X: both main diagonals must also
+// contain every digit.
+func diagonalRules(s shape) rules {
+	n := s.size
+	var down, up []int
+	for i := 0; i < n; i++ {
+		down = append(down, n*i+i)
+		up = append(up, n*i+n-1-i)
+	}
+	return append(shapeRules(s), houseConstraint{
+		groups: [][]int{down, up},
+		drawing: []hint{
+			{kind: lineHint, cells: down},
+			{kind: lineHint, cells: up},
+		},
+	})
+}
+
+// windokuRules are the rules of Windoku: four more 3x3 windows, one box away
+// from each corner of the board, must also contain every digit. They are
+// shaded on the board.
+func windokuRules() rules {
+	var windows [][]int
+	var drawing []hint
+	for _, corner := range []int{10, 14, 46, 50} {
+		var window []int
+		for i := 0; i < 9; i++ {
+			window = append(window, corner+9*(i/3)+i%3)
+		}
+		windows = append(windows, window)
+		drawing = append(drawing, hint{kind: shadeHint, cells: window})
+	}
+	return append(classicRules(), houseConstraint{
+		groups:  windows,
+		drawing: drawing,
+	})
+}